@@ -17,7 +17,10 @@ import (
 	"github.com/zyyp/backend/internal/database"
 	"github.com/zyyp/backend/internal/handlers"
 	"github.com/zyyp/backend/internal/middleware"
+	"github.com/zyyp/backend/internal/repository"
+	"github.com/zyyp/backend/pkg/imageproxy"
 	"github.com/zyyp/backend/pkg/rss"
+	"github.com/zyyp/backend/pkg/safehttp"
 )
 
 func main() {
@@ -32,6 +35,54 @@ func main() {
 	}
 	defer database.Close()
 
+	// Client for all server-side fetches of user-supplied URLs
+	allowedNetworks, err := safehttp.ParseNetworks(config.AppConfig.FetchAllowedNetworks)
+	if err != nil {
+		log.Fatalf("Invalid FETCH_ALLOWED_NETWORKS: %v", err)
+	}
+	fetchClient := safehttp.NewClient(safehttp.Options{
+		Timeout:         time.Duration(config.AppConfig.FetchTimeout) * time.Second,
+		MaxBodyBytes:    int64(config.AppConfig.FetchMaxSizeMB) << 20,
+		AllowedNetworks: allowedNetworks,
+	})
+
+	// Image proxy serving article images from the local cache
+	imageStorage, err := imageproxy.NewDiskStorage(config.AppConfig.ImageCacheDir)
+	if err != nil {
+		log.Fatalf("Failed to create image cache: %v", err)
+	}
+	imageProxy := imageproxy.New(imageproxy.Options{
+		Client:   fetchClient,
+		Storage:  imageStorage,
+		MaxBytes: int64(config.AppConfig.ImageMaxSizeMB) << 20,
+		BasePath: "/api/images",
+	})
+	handlers.SetImageProxy(imageProxy)
+
+	// RSS service shared by the cron job and the admin handlers
+	rssService := rss.NewService(rss.Options{
+		Client: fetchClient,
+
+		Concurrency:  config.AppConfig.RSSFetchConcurrency,
+		PerHostLimit: config.AppConfig.RSSFetchPerHost,
+		Timeout:      time.Duration(config.AppConfig.RSSFetchTimeout) * time.Second,
+
+		DefaultInterval: time.Duration(config.AppConfig.RSSFetchInterval) * time.Minute,
+		MinInterval:     time.Duration(config.AppConfig.RSSMinPollInterval) * time.Minute,
+		MaxInterval:     time.Duration(config.AppConfig.RSSMaxPollInterval) * time.Minute,
+
+		MaxFailures:   config.AppConfig.RSSMaxFailures,
+		KeepRevisions: config.AppConfig.RSSKeepRevisions,
+
+		ResolveCanonical: config.AppConfig.RSSResolveCanonical,
+
+		EnrichHostDelay: time.Duration(config.AppConfig.RSSEnrichHostDelay) * time.Second,
+
+		Images: imageProxy,
+	})
+	handlers.SetRSSService(rssService)
+	handlers.SetArticleRepository(repository.NewArticles(database.Pool))
+
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
 		AppName:       "Zyyp API",
@@ -66,9 +117,11 @@ func main() {
 	api.Get("/articles", middleware.OptionalAuth(), handlers.GetArticles)
 	api.Get("/articles/trending", middleware.OptionalAuth(), handlers.GetTrendingArticles)
 	api.Get("/articles/:id", middleware.OptionalAuth(), handlers.GetArticle)
+	api.Get("/search", middleware.OptionalAuth(), handlers.Search)
 	api.Get("/tags", handlers.GetTags)
 	api.Get("/tags/popular", handlers.GetPopularTags)
 	api.Get("/profiles/:id", handlers.GetProfileByID)
+	api.Get("/images/:hash", handlers.GetImage)
 
 	// Authenticated routes
 	auth := api.Group("", middleware.AuthRequired())
@@ -95,18 +148,48 @@ func main() {
 	admin.Post("/articles", handlers.CreateArticle)
 	admin.Get("/rss/sources", handlers.GetRSSSources)
 	admin.Post("/rss/sources", handlers.CreateRSSSource)
+	admin.Get("/rss/sources/:id", handlers.GetRSSSource)
+	admin.Patch("/rss/sources/:id", handlers.UpdateRSSSource)
+	admin.Delete("/rss/sources/:id", handlers.DeleteRSSSource)
+	admin.Post("/rss/sources/:id/fetch", handlers.FetchRSSSource)
+	admin.Post("/rss/preview", handlers.PreviewRSSSource)
+	admin.Get("/rss/opml", handlers.ExportOPML)
+	admin.Post("/rss/opml", handlers.ImportOPML)
 	admin.Post("/rss/fetch", handlers.TriggerRSSFetch)
-
-	// Start RSS cron job
-	rssService := rss.NewService()
-	c := cron.New()
-	c.AddFunc("@every 30m", func() {
+	admin.Get("/rss/runs", handlers.GetFetchRuns)
+	admin.Get("/rss/runs/:id", handlers.GetFetchRun)
+	admin.Get("/tag-rules", handlers.GetTagRules)
+	admin.Post("/tag-rules", handlers.CreateTagRule)
+	admin.Get("/tag-rules/apply", handlers.GetTagRulesRun)
+	admin.Post("/tag-rules/apply", handlers.ApplyTagRules)
+	admin.Delete("/tag-rules/:id", handlers.DeleteTagRule)
+
+	// Start RSS cron job. Each source carries its own schedule, so the job
+	// runs often and only picks up the sources that are due.
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DefaultLogger)))
+	c.AddFunc("@every 1m", func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 		defer cancel()
-		if err := rssService.FetchAllSources(ctx); err != nil {
+		if err := rssService.FetchDueSources(ctx); err != nil {
 			log.Printf("RSS fetch error: %v", err)
 		}
 	})
+
+	// Backfill images, descriptions and authors missing from feeds
+	if config.AppConfig.RSSEnrichMetadata {
+		c.AddFunc("@every 5m", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 4*time.Minute)
+			defer cancel()
+			updated, err := rssService.EnrichArticles(ctx)
+			if err != nil {
+				log.Printf("Article enrichment error: %v", err)
+			}
+			if updated > 0 {
+				log.Printf("Enriched %d articles from page metadata", updated)
+			}
+		})
+	}
+
 	c.Start()
 	defer c.Stop()
 
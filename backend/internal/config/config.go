@@ -17,6 +17,21 @@ type Config struct {
 	JWTSecret        string
 	CORSOrigins      string
 	RSSFetchInterval int
+	RSSFetchConcurrency int
+	RSSFetchPerHost  int
+	RSSFetchTimeout  int
+	RSSMinPollInterval int
+	RSSMaxPollInterval int
+	RSSMaxFailures   int
+	RSSKeepRevisions bool
+	RSSResolveCanonical bool
+	RSSEnrichMetadata bool
+	RSSEnrichHostDelay int
+	ImageCacheDir    string
+	ImageMaxSizeMB   int
+	FetchTimeout     int
+	FetchMaxSizeMB   int
+	FetchAllowedNetworks string
 }
 
 var AppConfig *Config
@@ -27,6 +42,19 @@ func Load() error {
 
 	corsOrigins := getEnv("CORS_ORIGINS", "http://localhost:5173")
 	rssFetchInterval, _ := strconv.Atoi(getEnv("RSS_FETCH_INTERVAL", "30"))
+	rssFetchConcurrency, _ := strconv.Atoi(getEnv("RSS_FETCH_CONCURRENCY", "8"))
+	rssFetchPerHost, _ := strconv.Atoi(getEnv("RSS_FETCH_PER_HOST", "2"))
+	rssFetchTimeout, _ := strconv.Atoi(getEnv("RSS_FETCH_TIMEOUT", "30"))
+	rssMinPollInterval, _ := strconv.Atoi(getEnv("RSS_MIN_POLL_INTERVAL", "10"))
+	rssMaxPollInterval, _ := strconv.Atoi(getEnv("RSS_MAX_POLL_INTERVAL", "1440"))
+	rssMaxFailures, _ := strconv.Atoi(getEnv("RSS_MAX_FAILURES", "10"))
+	rssKeepRevisions, _ := strconv.ParseBool(getEnv("RSS_KEEP_REVISIONS", "false"))
+	rssResolveCanonical, _ := strconv.ParseBool(getEnv("RSS_RESOLVE_CANONICAL", "false"))
+	rssEnrichMetadata, _ := strconv.ParseBool(getEnv("RSS_ENRICH_METADATA", "true"))
+	rssEnrichHostDelay, _ := strconv.Atoi(getEnv("RSS_ENRICH_HOST_DELAY", "2"))
+	imageMaxSizeMB, _ := strconv.Atoi(getEnv("IMAGE_MAX_SIZE_MB", "10"))
+	fetchTimeout, _ := strconv.Atoi(getEnv("FETCH_TIMEOUT", "30"))
+	fetchMaxSizeMB, _ := strconv.Atoi(getEnv("FETCH_MAX_SIZE_MB", "20"))
 
 	AppConfig = &Config{
 		Port:             getEnv("PORT", "8080"),
@@ -38,6 +66,21 @@ func Load() error {
 		JWTSecret:        getEnv("JWT_SECRET", ""),
 		CORSOrigins:      corsOrigins,
 		RSSFetchInterval: rssFetchInterval,
+		RSSFetchConcurrency: rssFetchConcurrency,
+		RSSFetchPerHost:  rssFetchPerHost,
+		RSSFetchTimeout:  rssFetchTimeout,
+		RSSMinPollInterval: rssMinPollInterval,
+		RSSMaxPollInterval: rssMaxPollInterval,
+		RSSMaxFailures:   rssMaxFailures,
+		RSSKeepRevisions: rssKeepRevisions,
+		RSSResolveCanonical: rssResolveCanonical,
+		RSSEnrichMetadata: rssEnrichMetadata,
+		RSSEnrichHostDelay: rssEnrichHostDelay,
+		ImageCacheDir:    getEnv("IMAGE_CACHE_DIR", "./data/images"),
+		ImageMaxSizeMB:   imageMaxSizeMB,
+		FetchTimeout:     fetchTimeout,
+		FetchMaxSizeMB:   fetchMaxSizeMB,
+		FetchAllowedNetworks: getEnv("FETCH_ALLOWED_NETWORKS", ""),
 	}
 
 	return nil
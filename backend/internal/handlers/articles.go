@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"math"
 	"strconv"
 	"strings"
@@ -10,12 +12,25 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/zyyp/backend/internal/database"
 	"github.com/zyyp/backend/internal/middleware"
 	"github.com/zyyp/backend/internal/models"
+	"github.com/zyyp/backend/internal/repository"
 )
 
-// GetArticles returns paginated articles with optional filters
+var articleRepo *repository.Articles
+
+// SetArticleRepository sets the repository used by the article listing
+// handlers
+func SetArticleRepository(r *repository.Articles) {
+	articleRepo = r
+}
+
+// GetArticles returns paginated articles with optional filters. Pages can be
+// requested by number or, to stay stable while new articles arrive, with the
+// next_cursor of the previous page.
 func GetArticles(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -40,66 +55,113 @@ func GetArticles(c *fiber.Ctx) error {
 		tags = strings.Split(tagsParam, ",")
 	}
 
-	// Build query
-	baseQuery := `
-		SELECT DISTINCT a.id, a.title, a.url, a.description, a.author, 
-			a.published_at, a.source_id, a.source_name, a.image_url, 
-			a.reading_time_minutes, a.upvotes, a.downvotes, a.created_at, a.updated_at
-		FROM articles a
-	`
+	var cursor *listCursor
+	if raw := c.Query("cursor"); raw != "" {
+		var err error
+		if cursor, err = decodeCursor(raw); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "Invalid cursor",
+			})
+		}
+	}
 
-	countQuery := `SELECT COUNT(DISTINCT a.id) FROM articles a`
-	
+	// Filters apply to every article; the matches are then collapsed to one
+	// per near-duplicate cluster
 	var whereConditions []string
 	var args []interface{}
-	argIndex := 1
-
-	// Join for tag filtering
-	if len(tags) > 0 {
-		baseQuery += ` JOIN article_tags at ON a.id = at.article_id JOIN tags t ON at.tag_id = t.id`
-		countQuery += ` JOIN article_tags at ON a.id = at.article_id JOIN tags t ON at.tag_id = t.id`
-		
-		placeholders := make([]string, len(tags))
-		for i, tag := range tags {
-			placeholders[i] = fmt.Sprintf("$%d", argIndex)
-			args = append(args, tag)
-			argIndex++
-		}
-		whereConditions = append(whereConditions, fmt.Sprintf("t.slug IN (%s)", strings.Join(placeholders, ",")))
-	}
 
-	// Search filter
+	// Full-text search
+	searchQuery := ""
 	if search != "" {
-		whereConditions = append(whereConditions, fmt.Sprintf("(a.title ILIKE $%d OR a.description ILIKE $%d)", argIndex, argIndex))
-		args = append(args, "%"+search+"%")
-		argIndex++
+		args = append(args, search)
+		searchQuery = fmt.Sprintf("websearch_to_tsquery('%s', $%d)", searchConfig, len(args))
+		whereConditions = append(whereConditions, "a.search_vector @@ "+searchQuery)
 	}
 
-	// Build WHERE clause
-	if len(whereConditions) > 0 {
-		whereClause := " WHERE " + strings.Join(whereConditions, " AND ")
-		baseQuery += whereClause
-		countQuery += whereClause
+	// Tag filtering
+	if len(tags) > 0 {
+		args = append(args, tags)
+		whereConditions = append(whereConditions, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM article_tags at JOIN tags t ON at.tag_id = t.id
+			WHERE at.article_id = a.id AND t.slug = ANY($%d)
+		)`, len(args)))
 	}
 
-	// Order by
-	switch sortBy {
-	case "popular":
-		baseQuery += " ORDER BY a.upvotes DESC, a.created_at DESC"
-	case "trending":
-		// Trending = recent + popular (weighted by recency)
-		baseQuery += " ORDER BY (a.upvotes * 1.0 / (EXTRACT(EPOCH FROM NOW() - a.created_at) / 3600 + 1)) DESC, a.created_at DESC"
+	filtered := whereClause(whereConditions)
+	countQuery := `SELECT COUNT(DISTINCT ` + storyKey + `) FROM articles a` + filtered
+	countArgs := args
+
+	// The cursor applies to the collapsed rows
+	var outerConditions []string
+
+	// Sort order
+	var keys []sortKey
+	var trendingRef *time.Time
+	switch {
+	case sortBy == "relevance" && search != "":
+		keys = []sortKey{
+			{expr: "ts_rank_cd(a.search_vector, " + searchQuery + ")", cast: "real"},
+			{expr: "a.published_at", cast: "timestamptz", nullable: true, nullsLast: true},
+			{expr: "a.id", cast: "uuid"},
+		}
+	case sortBy == "popular":
+		keys = []sortKey{
+			{expr: "a.upvotes", cast: "integer", nullable: true},
+			{expr: "a.created_at", cast: "timestamptz", nullable: true},
+			{expr: "a.id", cast: "uuid"},
+		}
+	case sortBy == "trending":
+		// Trending = recent + popular (weighted by recency). Scores are
+		// computed at the time of the first page so later pages line up.
+		ref := time.Now()
+		if cursor != nil && cursor.Ref != nil {
+			ref = *cursor.Ref
+		}
+		trendingRef = &ref
+		args = append(args, ref)
+		keys = []sortKey{
+			{expr: fmt.Sprintf("(COALESCE(a.upvotes, 0)::float8 / (EXTRACT(EPOCH FROM $%d::timestamptz - a.created_at)::float8 / 3600 + 1))", len(args)), cast: "float8", nullable: true},
+			{expr: "a.created_at", cast: "timestamptz", nullable: true},
+			{expr: "a.id", cast: "uuid"},
+		}
 	default: // newest
-		baseQuery += " ORDER BY a.published_at DESC NULLS LAST, a.created_at DESC"
+		sortBy = "newest"
+		keys = []sortKey{
+			{expr: "a.published_at", cast: "timestamptz", nullable: true, nullsLast: true},
+			{expr: "a.created_at", cast: "timestamptz", nullable: true},
+			{expr: "a.id", cast: "uuid"},
+		}
+	}
+
+	// Continue after the cursor, or skip to the requested page
+	pagination := ""
+	if cursor != nil {
+		if !cursor.fits(sortBy, keys) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid cursor",
+				Message: "The cursor was issued for a different sort order",
+			})
+		}
+		if !cursor.valid(keys) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "Invalid cursor",
+			})
+		}
+		var condition string
+		condition, args = after(keys, cursor.Values, args)
+		outerConditions = append(outerConditions, condition)
+	} else if offset > 0 {
+		args = append(args, offset)
+		pagination = fmt.Sprintf(" OFFSET $%d", len(args))
 	}
+	// One extra row tells whether there is another page
+	args = append(args, pageSize+1)
+	pagination = fmt.Sprintf(" LIMIT $%d", len(args)) + pagination
 
-	// Pagination
-	baseQuery += fmt.Sprintf(" LIMIT $%d OFFSET $%d", argIndex, argIndex+1)
-	args = append(args, pageSize, offset)
+	baseQuery := `SELECT ` + repository.ArticleColumns + keyColumns(keys) + `
+		FROM ` + collapseStories(filtered) + whereClause(outerConditions) + orderBy(keys) + pagination
 
-	// Get total count
 	var totalCount int
-	countArgs := args[:len(args)-2] // Remove pagination args
 	err := database.Pool.QueryRow(ctx, countQuery, countArgs...).Scan(&totalCount)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
@@ -118,18 +180,31 @@ func GetArticles(c *fiber.Ctx) error {
 	defer rows.Close()
 
 	var articles []models.Article
+	var keyValues [][]*string
 	for rows.Next() {
-		var a models.Article
-		err := rows.Scan(
-			&a.ID, &a.Title, &a.URL, &a.Description, &a.Author,
-			&a.PublishedAt, &a.SourceID, &a.SourceName, &a.ImageURL,
-			&a.ReadingTimeMinutes, &a.Upvotes, &a.Downvotes, &a.CreatedAt, &a.UpdatedAt,
-		)
+		values := make([]*string, len(keys))
+		dest := make([]interface{}, len(values))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		a, err := repository.ScanArticle(rows, dest...)
 		if err != nil {
 			continue
 		}
-		a.Tags = getArticleTags(ctx, a.ID)
 		articles = append(articles, a)
+		keyValues = append(keyValues, values)
+	}
+
+	hasMore := len(articles) > pageSize
+	if hasMore {
+		articles = articles[:pageSize]
+	}
+
+	articles = loadArticleTags(ctx, articles)
+	articles = enrichArticlesWithCoverage(ctx, articles)
+	articles = proxyArticleImages(articles)
+	if search != "" {
+		articles = enrichArticlesWithHeadlines(ctx, articles, search)
 	}
 
 	// Get user-specific data if authenticated
@@ -137,7 +212,11 @@ func GetArticles(c *fiber.Ctx) error {
 		articles = enrichArticlesWithUserData(ctx, articles, userID)
 	}
 
-	hasMore := totalCount > (page * pageSize)
+	var nextCursor *string
+	if hasMore {
+		next := encodeCursor(listCursor{Sort: sortBy, Values: keyValues[pageSize-1], Ref: trendingRef})
+		nextCursor = &next
+	}
 
 	return c.JSON(models.ArticlesResponse{
 		Articles:   articles,
@@ -145,6 +224,7 @@ func GetArticles(c *fiber.Ctx) error {
 		Page:       page,
 		PageSize:   pageSize,
 		HasMore:    hasMore,
+		NextCursor: nextCursor,
 	})
 }
 
@@ -164,12 +244,14 @@ func GetArticle(c *fiber.Ctx) error {
 	err = database.Pool.QueryRow(ctx, `
 		SELECT id, title, url, description, content, author, published_at, 
 			source_id, source_name, image_url, reading_time_minutes, 
-			upvotes, downvotes, created_at, updated_at
+			upvotes, downvotes, created_at, updated_at,
+			(SELECT s.favicon_url FROM rss_sources s WHERE s.id = articles.source_id)
 		FROM articles WHERE id = $1
 	`, articleID).Scan(
 		&a.ID, &a.Title, &a.URL, &a.Description, &a.Content, &a.Author,
 		&a.PublishedAt, &a.SourceID, &a.SourceName, &a.ImageURL,
 		&a.ReadingTimeMinutes, &a.Upvotes, &a.Downvotes, &a.CreatedAt, &a.UpdatedAt,
+		&a.FaviconURL,
 	)
 
 	if err != nil {
@@ -178,7 +260,9 @@ func GetArticle(c *fiber.Ctx) error {
 		})
 	}
 
-	a.Tags = getArticleTags(ctx, a.ID)
+	a = loadArticleTags(ctx, []models.Article{a})[0]
+	a = enrichArticlesWithCoverage(ctx, []models.Article{a})[0]
+	a = proxyArticleImages([]models.Article{a})[0]
 
 	// Get user-specific data
 	if userID, ok := middleware.GetUserID(c); ok {
@@ -202,13 +286,10 @@ func GetTrendingArticles(c *fiber.Ctx) error {
 	}
 
 	// Trending = upvotes weighted by recency (articles from last 7 days)
-	rows, err := database.Pool.Query(ctx, `
-		SELECT id, title, url, description, author, published_at, 
-			source_id, source_name, image_url, reading_time_minutes, 
-			upvotes, downvotes, created_at, updated_at
-		FROM articles
-		WHERE created_at > NOW() - INTERVAL '7 days'
-		ORDER BY (upvotes * 1.0 / (EXTRACT(EPOCH FROM NOW() - created_at) / 3600 + 1)) DESC
+	articles, err := articleRepo.List(ctx, `
+		SELECT `+repository.ArticleColumns+`
+		FROM `+collapseStories(` WHERE a.created_at > NOW() - INTERVAL '7 days'`)+`
+		ORDER BY (a.upvotes * 1.0 / (EXTRACT(EPOCH FROM NOW() - a.created_at) / 3600 + 1)) DESC
 		LIMIT $1
 	`, limit)
 	if err != nil {
@@ -216,22 +297,9 @@ func GetTrendingArticles(c *fiber.Ctx) error {
 			Error: "Failed to fetch trending articles",
 		})
 	}
-	defer rows.Close()
 
-	var articles []models.Article
-	for rows.Next() {
-		var a models.Article
-		err := rows.Scan(
-			&a.ID, &a.Title, &a.URL, &a.Description, &a.Author,
-			&a.PublishedAt, &a.SourceID, &a.SourceName, &a.ImageURL,
-			&a.ReadingTimeMinutes, &a.Upvotes, &a.Downvotes, &a.CreatedAt, &a.UpdatedAt,
-		)
-		if err != nil {
-			continue
-		}
-		a.Tags = getArticleTags(ctx, a.ID)
-		articles = append(articles, a)
-	}
+	articles = enrichArticlesWithCoverage(ctx, articles)
+	articles = proxyArticleImages(articles)
 
 	if userID, ok := middleware.GetUserID(c); ok {
 		articles = enrichArticlesWithUserData(ctx, articles, userID)
@@ -265,13 +333,21 @@ func CreateArticle(c *fiber.Ctx) error {
 		readingTime = int(math.Max(1, float64(wordCount)/200))
 	}
 
+	canonicalURL := rssService.CanonicalURL(ctx, req.URL)
+
 	var articleID uuid.UUID
 	err := database.Pool.QueryRow(ctx, `
-		INSERT INTO articles (title, url, description, author, source_name, image_url, reading_time_minutes, published_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		INSERT INTO articles (title, url, canonical_url, description, author, source_name, image_url, reading_time_minutes, published_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, NOW())
+		ON CONFLICT DO NOTHING
 		RETURNING id
-	`, req.Title, req.URL, req.Description, req.Author, req.SourceName, req.ImageURL, readingTime).Scan(&articleID)
+	`, req.Title, req.URL, canonicalURL, req.Description, req.Author, req.SourceName, req.ImageURL, readingTime).Scan(&articleID)
 
+	if isNoRows(err) {
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: "Article already exists",
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
 			Error:   "Failed to create article",
@@ -285,6 +361,7 @@ func CreateArticle(c *fiber.Ctx) error {
 			INSERT INTO article_tags (article_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING
 		`, articleID, tagID)
 	}
+	imageProxy.RegisterURLs(ctx, req.ImageURL)
 
 	return c.Status(fiber.StatusCreated).JSON(models.SuccessResponse{
 		Success: true,
@@ -295,26 +372,83 @@ func CreateArticle(c *fiber.Ctx) error {
 
 // Helper functions
 
-func getArticleTags(ctx context.Context, articleID uuid.UUID) []models.Tag {
+func isNoRows(err error) bool {
+	return errors.Is(err, pgx.ErrNoRows)
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// loadArticleTags sets the tags of articles in one query
+func loadArticleTags(ctx context.Context, articles []models.Article) []models.Article {
+	if err := articleRepo.LoadTags(ctx, articles); err != nil {
+		log.Printf("Error loading article tags: %v", err)
+	}
+	return articles
+}
+
+// storyKey identifies the story of an article aliased a: its near-duplicate
+// cluster, or the article itself when it is not clustered
+const storyKey = "COALESCE(a.cluster_id, a.id)"
+
+// collapseStories returns a subquery, aliased a, with one row per story
+// among the articles matching where. The cluster head is used when it
+// matches, otherwise the earliest matching member, so a story is not lost
+// when only a copy from another source matches the filters.
+func collapseStories(where string) string {
+	return `(SELECT DISTINCT ON (` + storyKey + `) a.* FROM articles a` + where + `
+		ORDER BY ` + storyKey + `, a.id <> ` + storyKey + `, a.created_at) a`
+}
+
+// whereClause joins conditions into a WHERE clause, empty without conditions
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// enrichArticlesWithCoverage lists the other sources that covered the same
+// story as each clustered article
+func enrichArticlesWithCoverage(ctx context.Context, articles []models.Article) []models.Article {
+	if len(articles) == 0 {
+		return articles
+	}
+
+	articleIDs := make([]uuid.UUID, len(articles))
+	articleMap := make(map[uuid.UUID]int)
+	for i, a := range articles {
+		articleIDs[i] = a.ID
+		articleMap[a.ID] = i
+	}
+
+	// The listed article may be the cluster head or, when only it matched
+	// the filters, another member
 	rows, err := database.Pool.Query(ctx, `
-		SELECT t.id, t.name, t.slug, t.color, t.created_at
-		FROM tags t
-		JOIN article_tags at ON t.id = at.tag_id
-		WHERE at.article_id = $1
-	`, articleID)
+		SELECT s.id, o.id, o.source_name, o.url
+		FROM articles s
+		JOIN articles o ON o.cluster_id = s.cluster_id AND o.id <> s.id
+		WHERE s.id = ANY($1)
+		ORDER BY o.created_at ASC
+	`, articleIDs)
 	if err != nil {
-		return nil
+		return articles
 	}
 	defer rows.Close()
 
-	var tags []models.Tag
 	for rows.Next() {
-		var t models.Tag
-		if err := rows.Scan(&t.ID, &t.Name, &t.Slug, &t.Color, &t.CreatedAt); err == nil {
-			tags = append(tags, t)
+		var listedID uuid.UUID
+		var coverage models.ArticleCoverage
+		if err := rows.Scan(&listedID, &coverage.ArticleID, &coverage.SourceName, &coverage.URL); err == nil {
+			if idx, ok := articleMap[listedID]; ok {
+				articles[idx].AlsoCoveredBy = append(articles[idx].AlsoCoveredBy, coverage)
+			}
 		}
 	}
-	return tags
+
+	return articles
 }
 
 func enrichArticlesWithUserData(ctx context.Context, articles []models.Article, userID uuid.UUID) []models.Article {
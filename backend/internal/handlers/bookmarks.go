@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 	"time"
 
@@ -10,9 +11,11 @@ import (
 	"github.com/zyyp/backend/internal/database"
 	"github.com/zyyp/backend/internal/middleware"
 	"github.com/zyyp/backend/internal/models"
+	"github.com/zyyp/backend/internal/repository"
 )
 
-// GetBookmarks returns the current user's bookmarks
+// GetBookmarks returns the current user's bookmarks, most recent first. Like
+// GetArticles it accepts either a page number or a cursor.
 func GetBookmarks(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -34,6 +37,37 @@ func GetBookmarks(c *fiber.Ctx) error {
 	}
 	offset := (page - 1) * pageSize
 
+	keys := []sortKey{
+		{expr: "b.created_at", cast: "timestamptz", nullable: true},
+		{expr: "b.id", cast: "uuid"},
+	}
+
+	var cursor *listCursor
+	if raw := c.Query("cursor"); raw != "" {
+		var err error
+		cursor, err = decodeCursor(raw)
+		if err != nil || !cursor.fits("bookmarked", keys) || !cursor.valid(keys) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "Invalid cursor",
+			})
+		}
+	}
+
+	where := "b.user_id = $1"
+	args := []interface{}{userID}
+	pagination := ""
+	if cursor != nil {
+		var condition string
+		condition, args = after(keys, cursor.Values, args)
+		where += " AND " + condition
+	} else if offset > 0 {
+		args = append(args, offset)
+		pagination = fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+	// One extra row tells whether there is another page
+	args = append(args, pageSize+1)
+	pagination = fmt.Sprintf(" LIMIT $%d", len(args)) + pagination
+
 	// Get total count
 	var totalCount int
 	err := database.Pool.QueryRow(ctx, `
@@ -47,15 +81,10 @@ func GetBookmarks(c *fiber.Ctx) error {
 
 	// Get bookmarked articles
 	rows, err := database.Pool.Query(ctx, `
-		SELECT a.id, a.title, a.url, a.description, a.author, a.published_at, 
-			a.source_id, a.source_name, a.image_url, a.reading_time_minutes, 
-			a.upvotes, a.downvotes, a.created_at, a.updated_at, b.created_at as bookmarked_at
+		SELECT `+repository.ArticleColumns+keyColumns(keys)+`
 		FROM articles a
 		JOIN bookmarks b ON a.id = b.article_id
-		WHERE b.user_id = $1
-		ORDER BY b.created_at DESC
-		LIMIT $2 OFFSET $3
-	`, userID, pageSize, offset)
+		WHERE `+where+orderBy(keys)+pagination, args...)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
 			Error: "Failed to fetch bookmarks",
@@ -64,26 +93,33 @@ func GetBookmarks(c *fiber.Ctx) error {
 	defer rows.Close()
 
 	var articles []models.Article
+	var keyValues [][]*string
 	for rows.Next() {
-		var a models.Article
-		var bookmarkedAt time.Time
-		err := rows.Scan(
-			&a.ID, &a.Title, &a.URL, &a.Description, &a.Author,
-			&a.PublishedAt, &a.SourceID, &a.SourceName, &a.ImageURL,
-			&a.ReadingTimeMinutes, &a.Upvotes, &a.Downvotes, &a.CreatedAt, &a.UpdatedAt, &bookmarkedAt,
-		)
+		values := make([]*string, len(keys))
+		a, err := repository.ScanArticle(rows, &values[0], &values[1])
 		if err != nil {
 			continue
 		}
 		a.IsBookmarked = true
-		a.Tags = getArticleTags(ctx, a.ID)
 		articles = append(articles, a)
+		keyValues = append(keyValues, values)
 	}
 
+	hasMore := len(articles) > pageSize
+	if hasMore {
+		articles = articles[:pageSize]
+	}
+	articles = loadArticleTags(ctx, articles)
+
 	// Get user votes for these articles
 	articles = enrichArticlesWithUserData(ctx, articles, userID)
+	articles = proxyArticleImages(articles)
 
-	hasMore := totalCount > (page * pageSize)
+	var nextCursor *string
+	if hasMore {
+		next := encodeCursor(listCursor{Sort: "bookmarked", Values: keyValues[pageSize-1]})
+		nextCursor = &next
+	}
 
 	return c.JSON(models.ArticlesResponse{
 		Articles:   articles,
@@ -91,6 +127,7 @@ func GetBookmarks(c *fiber.Ctx) error {
 		Page:       page,
 		PageSize:   pageSize,
 		HasMore:    hasMore,
+		NextCursor: nextCursor,
 	})
 }
 
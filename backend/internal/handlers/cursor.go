@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// sortKey is one expression of a listing's sort order. Listings sort
+// descending on every key, and the last key is unique so the order is total.
+type sortKey struct {
+	expr      string // SQL expression sorted on
+	cast      string // SQL type cursor values are cast back to
+	nullable  bool   // NULLs sort first, as by default for DESC
+	nullsLast bool   // NULLs of a nullable key sort last instead
+}
+
+// timestampLayouts are the forms PostgreSQL prints timestamptz values in,
+// depending on the offset of the session time zone
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07",
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999-07:00:00",
+}
+
+// accepts reports whether v is the text form of a value of the key's type
+func (k sortKey) accepts(v string) bool {
+	var err error
+	switch k.cast {
+	case "timestamptz":
+		for _, layout := range timestampLayouts {
+			if _, err = time.Parse(layout, v); err == nil {
+				break
+			}
+		}
+	case "uuid":
+		_, err = uuid.Parse(v)
+	case "integer":
+		_, err = strconv.ParseInt(v, 10, 32)
+	case "real":
+		_, err = strconv.ParseFloat(v, 32)
+	case "float8":
+		_, err = strconv.ParseFloat(v, 64)
+	default:
+		err = fmt.Errorf("unknown sort key type %q", k.cast)
+	}
+	return err == nil
+}
+
+// listCursor is the opaque position after the last row of a page, handed to
+// clients as next_cursor
+type listCursor struct {
+	Sort   string     `json:"s"`
+	Values []*string  `json:"v"`           // Sort key values of the last row, as text
+	Ref    *time.Time `json:"r,omitempty"` // Time trending scores are computed at
+}
+
+func encodeCursor(cur listCursor) string {
+	data, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var cur listCursor
+	if err := json.Unmarshal(data, &cur); err != nil {
+		return nil, errInvalidCursor
+	}
+	return &cur, nil
+}
+
+// fits reports whether the cursor was issued for the sort order
+func (cur *listCursor) fits(sort string, keys []sortKey) bool {
+	return cur.Sort == sort && len(cur.Values) == len(keys) && cur.Values[len(keys)-1] != nil
+}
+
+// valid reports whether every value of a cursor that fits the keys parses
+// as the key's type, so that a tampered cursor is rejected before it
+// reaches the query
+func (cur *listCursor) valid(keys []sortKey) bool {
+	for i, k := range keys {
+		if v := cur.Values[i]; v != nil && !k.accepts(*v) {
+			return false
+		}
+	}
+	return true
+}
+
+// orderBy returns the ORDER BY clause sorting on keys
+func orderBy(keys []sortKey) string {
+	terms := make([]string, len(keys))
+	for i, k := range keys {
+		terms[i] = k.expr + " DESC"
+		if k.nullable && k.nullsLast {
+			terms[i] += " NULLS LAST"
+		}
+	}
+	return " ORDER BY " + strings.Join(terms, ", ")
+}
+
+// keyColumns returns the keys as extra text columns to select, from which
+// the next cursor is built
+func keyColumns(keys []sortKey) string {
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, ", (%s)::text", k.expr)
+	}
+	return b.String()
+}
+
+// after returns a condition matching the rows that sort after the row with
+// the given key values, appending its arguments to args
+func after(keys []sortKey, values []*string, args []interface{}) (string, []interface{}) {
+	var alternatives, equal []string
+	for i, k := range keys {
+		v := values[i]
+		if v == nil {
+			// Every value sorts after a NULL that sorts first, and nothing
+			// after one that sorts last
+			if k.nullable && !k.nullsLast {
+				terms := append(append([]string{}, equal...), k.expr+" IS NOT NULL")
+				alternatives = append(alternatives, strings.Join(terms, " AND "))
+			}
+			equal = append(equal, k.expr+" IS NULL")
+			continue
+		}
+
+		args = append(args, *v)
+		param := fmt.Sprintf("$%d::%s", len(args), k.cast)
+		beyond := k.expr + " < " + param
+		if k.nullable && k.nullsLast {
+			beyond = "(" + beyond + " OR " + k.expr + " IS NULL)"
+		}
+
+		terms := append(append([]string{}, equal...), beyond)
+		alternatives = append(alternatives, strings.Join(terms, " AND "))
+		equal = append(equal, k.expr+" = "+param)
+	}
+	if len(alternatives) == 0 {
+		return "FALSE", args
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
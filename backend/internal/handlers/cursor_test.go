@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+var newestKeys = []sortKey{
+	{expr: "a.published_at", cast: "timestamptz", nullable: true, nullsLast: true},
+	{expr: "a.id", cast: "uuid"},
+}
+
+var popularKeys = []sortKey{
+	{expr: "a.upvotes", cast: "integer", nullable: true},
+	{expr: "a.created_at", cast: "timestamptz", nullable: true},
+	{expr: "a.id", cast: "uuid"},
+}
+
+const lastID = "7b0c5a5e-8f7a-4c1e-9a34-1f2d3c4b5a69"
+
+func str(s string) *string { return &s }
+
+func TestAfter(t *testing.T) {
+	tests := []struct {
+		name      string
+		keys      []sortKey
+		values    []*string
+		args      []interface{}
+		condition string
+		wantArgs  []interface{}
+	}{
+		{
+			name:      "tie-breaker on id",
+			keys:      newestKeys,
+			values:    []*string{str("2024-03-01 12:00:00+00"), str(lastID)},
+			condition: "((a.published_at < $1::timestamptz OR a.published_at IS NULL) OR a.published_at = $1::timestamptz AND a.id < $2::uuid)",
+			wantArgs:  []interface{}{"2024-03-01 12:00:00+00", lastID},
+		},
+		{
+			// NULLs sort last, so after a NULL only rows that are also NULL
+			// and have a lower id follow
+			name:      "NULL key",
+			keys:      newestKeys,
+			values:    []*string{nil, str(lastID)},
+			condition: "(a.published_at IS NULL AND a.id < $1::uuid)",
+			wantArgs:  []interface{}{lastID},
+		},
+		{
+			name:      "numbering continues after filter args",
+			keys:      newestKeys,
+			values:    []*string{str("2024-03-01 12:00:00+00"), str(lastID)},
+			args:      []interface{}{"golang", []string{"go"}},
+			condition: "((a.published_at < $3::timestamptz OR a.published_at IS NULL) OR a.published_at = $3::timestamptz AND a.id < $4::uuid)",
+			wantArgs:  []interface{}{"golang", []string{"go"}, "2024-03-01 12:00:00+00", lastID},
+		},
+		{
+			// NULLs sort first, so every non-NULL value follows a NULL
+			name:      "NULL middle key",
+			keys:      popularKeys,
+			values:    []*string{str("5"), nil, str(lastID)},
+			args:      []interface{}{"ref"},
+			condition: "(a.upvotes < $2::integer OR a.upvotes = $2::integer AND a.created_at IS NOT NULL OR a.upvotes = $2::integer AND a.created_at IS NULL AND a.id < $3::uuid)",
+			wantArgs:  []interface{}{"ref", "5", lastID},
+		},
+		{
+			name:      "NULL first key",
+			keys:      popularKeys,
+			values:    []*string{nil, str("2024-03-01 12:00:00+00"), str(lastID)},
+			condition: "(a.upvotes IS NOT NULL OR a.upvotes IS NULL AND a.created_at < $1::timestamptz OR a.upvotes IS NULL AND a.created_at = $1::timestamptz AND a.id < $2::uuid)",
+			wantArgs:  []interface{}{"2024-03-01 12:00:00+00", lastID},
+		},
+		{
+			name:      "nothing sorts after all NULLs",
+			keys:      newestKeys[:1],
+			values:    []*string{nil},
+			condition: "FALSE",
+		},
+	}
+	for _, tt := range tests {
+		condition, args := after(tt.keys, tt.values, tt.args)
+		if condition != tt.condition {
+			t.Errorf("%s: condition\n%s\nwant\n%s", tt.name, condition, tt.condition)
+		}
+		if !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("%s: args = %v, want %v", tt.name, args, tt.wantArgs)
+		}
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	tests := []struct {
+		keys []sortKey
+		want string
+	}{
+		{newestKeys, " ORDER BY a.published_at DESC NULLS LAST, a.id DESC"},
+		{popularKeys, " ORDER BY a.upvotes DESC, a.created_at DESC, a.id DESC"},
+	}
+	for _, tt := range tests {
+		if got := orderBy(tt.keys); got != tt.want {
+			t.Errorf("orderBy = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	cur := listCursor{Sort: "newest", Values: []*string{nil, str(lastID)}}
+	decoded, err := decodeCursor(encodeCursor(cur))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*decoded, cur) {
+		t.Errorf("decoded %+v, want %+v", *decoded, cur)
+	}
+	if !decoded.fits("newest", newestKeys) || !decoded.valid(newestKeys) {
+		t.Error("expected the cursor to fit its sort order")
+	}
+	if decoded.fits("popular", newestKeys) {
+		t.Error("expected the cursor not to fit another sort order")
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	for _, raw := range []string{"not base64!", encodeRaw("not json"), encodeRaw(`{"s": 1}`)} {
+		if _, err := decodeCursor(raw); err != errInvalidCursor {
+			t.Errorf("decodeCursor(%q): expected errInvalidCursor, got %v", raw, err)
+		}
+	}
+}
+
+func TestCursorValues(t *testing.T) {
+	tests := []struct {
+		cast  string
+		value string
+		ok    bool
+	}{
+		{"timestamptz", "2024-03-01 12:00:00+00", true},
+		{"timestamptz", "2024-03-01 12:00:00.123456+00", true},
+		{"timestamptz", "2024-03-01 17:30:00.5+05:30", true},
+		{"timestamptz", "1890-01-01 00:00:00+00:53:28", true},
+		{"timestamptz", "2024-03-01T12:00:00Z", false},
+		{"timestamptz", "yesterday", false},
+		{"timestamptz", "2024-03-01 12:00:00+00'; DROP TABLE articles; --", false},
+		{"uuid", lastID, true},
+		{"uuid", "42", false},
+		{"integer", "-3", true},
+		{"integer", "3.5", false},
+		{"integer", "99999999999", false},
+		{"real", "0.0607927", true},
+		{"real", "1e-05", true},
+		{"real", "high", false},
+		{"float8", "12.345678901234", true},
+		{"float8", "", false},
+		{"text", "anything", false},
+	}
+	for _, tt := range tests {
+		keys := []sortKey{{expr: "x", cast: tt.cast}}
+		cur := listCursor{Values: []*string{&tt.value}}
+		if got := cur.valid(keys); got != tt.ok {
+			t.Errorf("%s value %q: valid = %v, want %v", tt.cast, tt.value, got, tt.ok)
+		}
+	}
+}
+
+func encodeRaw(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
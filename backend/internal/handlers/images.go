@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zyyp/backend/internal/models"
+	"github.com/zyyp/backend/pkg/imageproxy"
+)
+
+var imageProxy *imageproxy.Proxy
+
+// SetImageProxy sets the image proxy used to serve article images
+func SetImageProxy(p *imageproxy.Proxy) {
+	imageProxy = p
+}
+
+// GetImage serves a resized copy of a proxied article image or favicon. The
+// optional w query parameter picks the thumbnail width.
+func GetImage(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	width, _ := strconv.Atoi(c.Query("w"))
+	webp := strings.Contains(c.Get(fiber.HeaderAccept), "image/webp")
+	data, contentType, err := imageProxy.Image(ctx, c.Params("hash"), width, webp)
+	switch {
+	case errors.Is(err, imageproxy.ErrInvalidHash):
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid image hash",
+		})
+	case errors.Is(err, imageproxy.ErrUnknownImage):
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: "Image not found",
+		})
+	case errors.Is(err, imageproxy.ErrNotAnImage), errors.Is(err, imageproxy.ErrTooLarge):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(models.ErrorResponse{
+			Error:   "Image cannot be proxied",
+			Message: err.Error(),
+		})
+	case err != nil:
+		return c.Status(fiber.StatusBadGateway).JSON(models.ErrorResponse{
+			Error: "Failed to fetch image",
+		})
+	}
+
+	// Thumbnails for a hash never change, but the format depends on Accept
+	c.Set(fiber.HeaderCacheControl, "public, max-age=31536000, immutable")
+	c.Set(fiber.HeaderVary, fiber.HeaderAccept)
+	c.Set(fiber.HeaderContentType, contentType)
+	return c.Send(data)
+}
+
+// proxyArticleImages points the image and favicon URLs of articles at the
+// image proxy. The originals are registered when they are stored.
+func proxyArticleImages(articles []models.Article) []models.Article {
+	if imageProxy == nil {
+		return articles
+	}
+	for i := range articles {
+		articles[i].ImageURL = proxiedURL(articles[i].ImageURL)
+		articles[i].FaviconURL = proxiedURL(articles[i].FaviconURL)
+	}
+	return articles
+}
+
+func proxiedURL(u *string) *string {
+	if u == nil || *u == "" {
+		return u
+	}
+	proxied := imageProxy.URL(*u)
+	return &proxied
+}
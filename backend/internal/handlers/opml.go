@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/zyyp/backend/internal/config"
+	"github.com/zyyp/backend/internal/database"
+	"github.com/zyyp/backend/internal/models"
+	"github.com/zyyp/backend/pkg/opml"
+)
+
+// ImportOPML creates RSS sources in bulk from an OPML document, sent either
+// as the raw request body or as a multipart "file" upload. Sources whose URL
+// already exists are skipped, and outline categories become source tags,
+// which are added to every article ingested from the source.
+func ImportOPML(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	body := c.Body()
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "Invalid OPML file",
+			})
+		}
+		defer f.Close()
+		if body, err = io.ReadAll(f); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "Invalid OPML file",
+			})
+		}
+	}
+
+	feeds, err := opml.Parse(bytes.NewReader(body))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid OPML document",
+			Message: err.Error(),
+		})
+	}
+
+	result := models.OPMLImportResult{
+		Created: []models.OPMLImportedSource{},
+		Skipped: []string{},
+		Errors:  []string{},
+	}
+	tagIDs := make(map[string]uuid.UUID)
+
+	for _, feed := range feeds {
+		if feed.URL == "" {
+			continue
+		}
+		if !isHTTPURL(feed.URL) {
+			result.Errors = append(result.Errors, feed.URL+": only http and https feeds are supported")
+			continue
+		}
+		name := feed.Name
+		if name == "" {
+			name = feed.URL
+		}
+
+		var sourceID uuid.UUID
+		err := database.Pool.QueryRow(ctx, `
+			INSERT INTO rss_sources (name, url, poll_interval_minutes)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (url) DO NOTHING
+			RETURNING id
+		`, name, feed.URL, config.AppConfig.RSSFetchInterval).Scan(&sourceID)
+		if err != nil {
+			// No row is returned when the URL already exists
+			if isNoRows(err) {
+				result.Skipped = append(result.Skipped, feed.URL)
+			} else {
+				result.Errors = append(result.Errors, feed.URL+": "+err.Error())
+			}
+			continue
+		}
+
+		var tags []string
+		for _, category := range feed.Categories {
+			tagID, ok := tagIDs[category]
+			if !ok {
+				if tagID, err = ensureTag(ctx, category); err != nil {
+					result.Errors = append(result.Errors, feed.URL+": tag "+category+": "+err.Error())
+					continue
+				}
+				tagIDs[category] = tagID
+			}
+			_, err := database.Pool.Exec(ctx, `
+				INSERT INTO rss_source_tags (source_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING
+			`, sourceID, tagID)
+			if err == nil {
+				tags = append(tags, category)
+			}
+		}
+
+		result.Created = append(result.Created, models.OPMLImportedSource{
+			ID:   sourceID,
+			Name: name,
+			URL:  feed.URL,
+			Tags: tags,
+		})
+	}
+
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    result,
+		Message: "OPML imported",
+	})
+}
+
+// ExportOPML returns all RSS sources as an OPML document, with each
+// source's tags as outline categories
+func ExportOPML(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := database.Pool.Query(ctx, `
+		SELECT s.name, s.url, COALESCE(array_agg(t.name ORDER BY t.name) FILTER (WHERE t.id IS NOT NULL), '{}')
+		FROM rss_sources s
+		LEFT JOIN rss_source_tags st ON st.source_id = s.id
+		LEFT JOIN tags t ON t.id = st.tag_id
+		GROUP BY s.id
+		ORDER BY s.name ASC
+	`)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "Failed to fetch RSS sources",
+		})
+	}
+	defer rows.Close()
+
+	var feeds []opml.Feed
+	for rows.Next() {
+		var f opml.Feed
+		if err := rows.Scan(&f.Name, &f.URL, &f.Categories); err == nil {
+			feeds = append(feeds, f)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := opml.Write(&buf, "Zyyp RSS sources", feeds); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "Failed to encode OPML",
+		})
+	}
+
+	c.Attachment("zyyp-sources.opml")
+	c.Set(fiber.HeaderContentType, "text/x-opml; charset=utf-8")
+	return c.Send(buf.Bytes())
+}
+
+// ensureTag returns the ID of the tag with the given name, creating it when
+// no tag with that name or slug exists
+func ensureTag(ctx context.Context, name string) (uuid.UUID, error) {
+	slug := slugify(name)
+	if slug == "" {
+		return uuid.Nil, fmt.Errorf("invalid tag name %q", name)
+	}
+
+	var tagID uuid.UUID
+	err := database.Pool.QueryRow(ctx, `
+		SELECT id FROM tags WHERE slug = $1 OR LOWER(name) = LOWER($2) LIMIT 1
+	`, slug, name).Scan(&tagID)
+	if err == nil {
+		return tagID, nil
+	}
+	if !isNoRows(err) {
+		return uuid.Nil, err
+	}
+
+	err = database.Pool.QueryRow(ctx, `
+		INSERT INTO tags (name, slug) VALUES ($1, $2)
+		ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+		RETURNING id
+	`, name, slug).Scan(&tagID)
+	return tagID, err
+}
+
+// isHTTPURL reports whether raw is an absolute http or https URL
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slugify turns a tag name into a URL-safe slug ("Node.js" -> "node-js")
+func slugify(name string) string {
+	return strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
@@ -2,26 +2,34 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/zyyp/backend/internal/config"
 	"github.com/zyyp/backend/internal/database"
 	"github.com/zyyp/backend/internal/models"
 	"github.com/zyyp/backend/pkg/rss"
 )
 
-var rssService = rss.NewService()
+var rssService *rss.Service
 
-// GetRSSSources returns all active RSS sources
+// SetRSSService sets the RSS service used by the admin handlers
+func SetRSSService(s *rss.Service) {
+	rssService = s
+}
+
+// GetRSSSources returns all RSS sources with their fetch health
 func GetRSSSources(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	rows, err := database.Pool.Query(ctx, `
-		SELECT id, name, url, favicon_url, active, last_fetched_at, created_at
-		FROM rss_sources
-		ORDER BY name ASC
-	`)
+	rows, err := database.Pool.Query(ctx, `SELECT `+rssSourceColumns+` FROM rss_sources ORDER BY name ASC`)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
 			Error: "Failed to fetch RSS sources",
@@ -32,7 +40,7 @@ func GetRSSSources(c *fiber.Ctx) error {
 	var sources []models.RSSSource
 	for rows.Next() {
 		var s models.RSSSource
-		if err := rows.Scan(&s.ID, &s.Name, &s.URL, &s.FaviconURL, &s.Active, &s.LastFetchedAt, &s.CreatedAt); err == nil {
+		if err := scanRSSSource(rows, &s); err == nil {
 			sources = append(sources, s)
 		}
 	}
@@ -40,9 +48,12 @@ func GetRSSSources(c *fiber.Ctx) error {
 	return c.JSON(sources)
 }
 
-// CreateRSSSource adds a new RSS source
+// CreateRSSSource adds a new RSS source. The URL may be a feed or a website
+// that advertises one; the feed is validated before the source is stored and
+// missing name and favicon are filled in from the feed and page. Default
+// tags, filters and full content extraction can be set in the same request.
 func CreateRSSSource(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
 	var req models.CreateRSSSourceRequest
@@ -52,19 +63,60 @@ func CreateRSSSource(c *fiber.Ctx) error {
 		})
 	}
 
-	if req.Name == "" || req.URL == "" {
+	if req.URL == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
-			Error: "Name and URL are required",
+			Error: "URL is required",
+		})
+	}
+
+	if req.Filters != nil {
+		if err := rss.ValidateFilters(*req.Filters); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid filters",
+				Message: err.Error(),
+			})
+		}
+	}
+
+	discovery, err := rssService.Discover(ctx, req.URL)
+	if err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(models.ErrorResponse{
+			Error:   "No usable feed found",
+			Message: rss.DescribeDiscoveryError(err),
+		})
+	}
+
+	req.URL = discovery.FeedURL
+	if req.Name == "" {
+		req.Name = discovery.Title
+	}
+	if req.Name == "" {
+		req.Name = discovery.FeedURL
+	}
+	if req.FaviconURL == nil {
+		req.FaviconURL = discovery.FaviconURL
+	}
+
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "Failed to create RSS source",
 		})
 	}
+	defer tx.Rollback(ctx)
 
 	var sourceID string
-	err := database.Pool.QueryRow(ctx, `
-		INSERT INTO rss_sources (name, url, favicon_url)
-		VALUES ($1, $2, $3)
+	err = tx.QueryRow(ctx, `
+		INSERT INTO rss_sources (name, url, favicon_url, poll_interval_minutes, filters, fetch_full_content)
+		VALUES ($1, $2, $3, $4, COALESCE($5, '{"include": [], "exclude": []}'::jsonb), $6)
 		RETURNING id
-	`, req.Name, req.URL, req.FaviconURL).Scan(&sourceID)
+	`, req.Name, req.URL, req.FaviconURL, config.AppConfig.RSSFetchInterval, req.Filters, req.FetchFullContent).Scan(&sourceID)
 
+	if isUniqueViolation(err) {
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: "An RSS source with this URL already exists",
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
 			Error:   "Failed to create RSS source",
@@ -72,26 +124,465 @@ func CreateRSSSource(c *fiber.Ctx) error {
 		})
 	}
 
+	if len(req.TagIDs) > 0 {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO rss_source_tags (source_id, tag_id)
+			SELECT DISTINCT $1::uuid, unnest($2::uuid[])
+		`, sourceID, req.TagIDs); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid tag IDs",
+				Message: err.Error(),
+			})
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "Failed to create RSS source",
+		})
+	}
+	imageProxy.RegisterURLs(ctx, req.FaviconURL)
+
 	return c.Status(fiber.StatusCreated).JSON(models.SuccessResponse{
 		Success: true,
-		Data:    map[string]interface{}{"id": sourceID},
+		Data: map[string]interface{}{
+			"id":                 sourceID,
+			"name":               req.Name,
+			"url":                req.URL,
+			"favicon_url":        req.FaviconURL,
+			"tag_ids":            req.TagIDs,
+			"fetch_full_content": req.FetchFullContent,
+		},
 		Message: "RSS source created",
 	})
 }
 
-// TriggerRSSFetch manually triggers fetching from all RSS sources
+// GetRSSSource returns a single RSS source with its default tags
+func GetRSSSource(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sourceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid source ID",
+		})
+	}
+
+	source, err := loadRSSSource(ctx, sourceID)
+	if err != nil {
+		if isNoRows(err) {
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error: "RSS source not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Failed to fetch RSS source",
+			Message: err.Error(),
+		})
+	}
+
+	return c.JSON(source)
+}
+
+// UpdateRSSSource updates an RSS source. Only the fields present in the
+// request are changed; tag_ids replaces the source's default tags. A new URL
+// is validated like on create, and resuming a paused source clears its
+// failure count and schedules it for the next fetch.
+func UpdateRSSSource(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	sourceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid source ID",
+		})
+	}
+
+	var req models.UpdateRSSSourceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid request body",
+		})
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "Name cannot be empty",
+			})
+		}
+		req.Name = &name
+	}
+
+	if req.Filters != nil {
+		if err := rss.ValidateFilters(*req.Filters); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid filters",
+				Message: err.Error(),
+			})
+		}
+	}
+
+	if req.URL != nil {
+		discovery, err := rssService.Discover(ctx, *req.URL)
+		if err != nil {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(models.ErrorResponse{
+				Error:   "No usable feed found",
+				Message: rss.DescribeDiscoveryError(err),
+			})
+		}
+		req.URL = &discovery.FeedURL
+	}
+
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "Failed to update RSS source",
+		})
+	}
+	defer tx.Rollback(ctx)
+
+	// Changing the URL drops the validators of the old feed
+	err = tx.QueryRow(ctx, `
+		UPDATE rss_sources
+		SET name = COALESCE($2, name),
+			url = COALESCE($3, url),
+			favicon_url = CASE WHEN $4::text IS NULL THEN favicon_url ELSE NULLIF($4, '') END,
+			active = COALESCE($5, active),
+			consecutive_failures = CASE WHEN $5 AND NOT active THEN 0 ELSE consecutive_failures END,
+			next_fetch_at = CASE WHEN ($5 AND NOT active) OR COALESCE($3, url) <> url THEN NOW() ELSE next_fetch_at END,
+			etag = CASE WHEN COALESCE($3, url) <> url THEN NULL ELSE etag END,
+			last_modified = CASE WHEN COALESCE($3, url) <> url THEN NULL ELSE last_modified END,
+			content_hash = CASE WHEN COALESCE($3, url) <> url THEN NULL ELSE content_hash END,
+			filters = COALESCE($6, filters),
+			fetch_full_content = COALESCE($7, fetch_full_content)
+		WHERE id = $1
+		RETURNING id
+	`, sourceID, req.Name, req.URL, req.FaviconURL, req.Active, req.Filters, req.FetchFullContent).Scan(&sourceID)
+
+	if isNoRows(err) {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: "RSS source not found",
+		})
+	}
+	if isUniqueViolation(err) {
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: "An RSS source with this URL already exists",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Failed to update RSS source",
+			Message: err.Error(),
+		})
+	}
+
+	if req.TagIDs != nil {
+		if _, err := tx.Exec(ctx, `DELETE FROM rss_source_tags WHERE source_id = $1`, sourceID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error: "Failed to update RSS source",
+			})
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO rss_source_tags (source_id, tag_id)
+			SELECT DISTINCT $1::uuid, unnest($2::uuid[])
+		`, sourceID, *req.TagIDs); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid tag IDs",
+				Message: err.Error(),
+			})
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "Failed to update RSS source",
+		})
+	}
+	imageProxy.RegisterURLs(ctx, req.FaviconURL)
+
+	source, err := loadRSSSource(ctx, sourceID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Failed to fetch RSS source",
+			Message: err.Error(),
+		})
+	}
+
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    source,
+		Message: "RSS source updated",
+	})
+}
+
+// DeleteRSSSource deletes an RSS source. Its articles are kept without a
+// source unless ?articles=delete is given.
+func DeleteRSSSource(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	sourceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid source ID",
+		})
+	}
+
+	mode := c.Query("articles", "keep")
+	if mode != "keep" && mode != "delete" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "articles must be keep or delete",
+		})
+	}
+
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "Failed to delete RSS source",
+		})
+	}
+	defer tx.Rollback(ctx)
+
+	var articlesDeleted int64
+	if mode == "delete" {
+		tag, err := tx.Exec(ctx, `DELETE FROM articles WHERE source_id = $1`, sourceID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:   "Failed to delete source articles",
+				Message: err.Error(),
+			})
+		}
+		articlesDeleted = tag.RowsAffected()
+	}
+
+	tag, err := tx.Exec(ctx, `DELETE FROM rss_sources WHERE id = $1`, sourceID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Failed to delete RSS source",
+			Message: err.Error(),
+		})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: "RSS source not found",
+		})
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "Failed to delete RSS source",
+		})
+	}
+
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    map[string]interface{}{"articles_deleted": articlesDeleted},
+		Message: "RSS source deleted",
+	})
+}
+
+// FetchRSSSource fetches a single RSS source right away and returns what
+// was ingested
+func FetchRSSSource(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	sourceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid source ID",
+		})
+	}
+
+	result, err := rssService.FetchSourceByID(ctx, sourceID)
+	if errors.Is(err, rss.ErrSourceNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: "RSS source not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(models.ErrorResponse{
+			Error:   "Failed to fetch RSS source",
+			Message: err.Error(),
+		})
+	}
+
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// PreviewRSSSource shows what fetching a feed would produce without
+// storing anything
+func PreviewRSSSource(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var req models.PreviewRSSSourceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid request body",
+		})
+	}
+
+	if req.URL == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "URL is required",
+		})
+	}
+
+	preview, err := rssService.Preview(ctx, req.URL)
+	if err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(models.ErrorResponse{
+			Error:   "Failed to preview feed",
+			Message: rss.DescribeDiscoveryError(err),
+		})
+	}
+
+	return c.JSON(preview)
+}
+
+// TriggerRSSFetch manually triggers fetching from all RSS sources. The fetch
+// runs in the background; its report is available from GetFetchRun.
 func TriggerRSSFetch(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	runID, err := rssService.StartRun(ctx, rss.TriggerManual)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Failed to start RSS fetch",
+			Message: err.Error(),
+		})
+	}
+
 	go func() {
-		if err := rssService.FetchAllSources(ctx); err != nil {
-			// Log error
+		// The fetch outlives the request, so it gets its own context
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+
+		if err := rssService.FetchAllSources(ctx, runID); err != nil {
+			log.Printf("RSS fetch run %s error: %v", runID, err)
 		}
 	}()
 
-	return c.JSON(models.SuccessResponse{
+	return c.Status(fiber.StatusAccepted).JSON(models.SuccessResponse{
 		Success: true,
+		Data:    map[string]interface{}{"run_id": runID},
 		Message: "RSS fetch triggered",
 	})
 }
+
+// GetFetchRuns returns the most recent RSS fetch runs
+func GetFetchRuns(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	rows, err := database.Pool.Query(ctx, `
+		SELECT `+fetchRunColumns+`
+		FROM fetch_runs
+		ORDER BY started_at DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "Failed to fetch RSS runs",
+		})
+	}
+	defer rows.Close()
+
+	runs := []models.FetchRun{}
+	for rows.Next() {
+		var r models.FetchRun
+		if err := scanFetchRun(rows, &r); err == nil {
+			runs = append(runs, r)
+		}
+	}
+
+	return c.JSON(runs)
+}
+
+// GetFetchRun returns the ingestion report of a single RSS fetch run
+func GetFetchRun(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	runID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid run ID",
+		})
+	}
+
+	var r models.FetchRun
+	row := database.Pool.QueryRow(ctx, `SELECT `+fetchRunColumns+` FROM fetch_runs WHERE id = $1`, runID)
+	if err := scanFetchRun(row, &r); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: "Fetch run not found",
+		})
+	}
+
+	return c.JSON(r)
+}
+
+const rssSourceColumns = `id, name, url, favicon_url, active, last_fetched_at,
+	poll_interval_minutes, next_fetch_at, consecutive_failures,
+	last_error, last_status_code, last_success_at, created_at, filters, fetch_full_content`
+
+func scanRSSSource(row pgx.Row, s *models.RSSSource) error {
+	return row.Scan(
+		&s.ID, &s.Name, &s.URL, &s.FaviconURL, &s.Active, &s.LastFetchedAt,
+		&s.PollIntervalMinutes, &s.NextFetchAt, &s.ConsecutiveFailures,
+		&s.LastError, &s.LastStatusCode, &s.LastSuccessAt, &s.CreatedAt, &s.Filters, &s.FetchFullContent,
+	)
+}
+
+// loadRSSSource loads a source and its default tags
+func loadRSSSource(ctx context.Context, sourceID uuid.UUID) (*models.RSSSource, error) {
+	var s models.RSSSource
+	row := database.Pool.QueryRow(ctx, `SELECT `+rssSourceColumns+` FROM rss_sources WHERE id = $1`, sourceID)
+	if err := scanRSSSource(row, &s); err != nil {
+		return nil, err
+	}
+
+	rows, err := database.Pool.Query(ctx, `
+		SELECT t.id, t.name, t.slug, t.color, t.created_at
+		FROM tags t
+		JOIN rss_source_tags st ON st.tag_id = t.id
+		WHERE st.source_id = $1
+		ORDER BY t.name ASC
+	`, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	s.Tags = []models.Tag{}
+	for rows.Next() {
+		var t models.Tag
+		if err := rows.Scan(&t.ID, &t.Name, &t.Slug, &t.Color, &t.CreatedAt); err == nil {
+			s.Tags = append(s.Tags, t)
+		}
+	}
+	return &s, rows.Err()
+}
+
+const fetchRunColumns = `id, trigger, status, started_at, finished_at, sources_attempted,
+	items_seen, items_filtered, articles_inserted, articles_updated, duplicates_skipped, errors, error`
+
+func scanFetchRun(row pgx.Row, r *models.FetchRun) error {
+	return row.Scan(
+		&r.ID, &r.Trigger, &r.Status, &r.StartedAt, &r.FinishedAt, &r.SourcesAttempted,
+		&r.ItemsSeen, &r.ItemsFiltered, &r.ArticlesInserted, &r.ArticlesUpdated, &r.DuplicatesSkipped, &r.Errors, &r.Error,
+	)
+}
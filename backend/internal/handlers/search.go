@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/zyyp/backend/internal/database"
+	"github.com/zyyp/backend/internal/middleware"
+	"github.com/zyyp/backend/internal/models"
+	"github.com/zyyp/backend/internal/repository"
+)
+
+// searchConfig is the text search configuration articles.search_vector is
+// built with; queries must use the same one to match
+const searchConfig = "english"
+
+const (
+	highlightStart = "<mark>"
+	highlightStop  = "</mark>"
+)
+
+// facetLimit is the most values returned per facet
+const facetLimit = 10
+
+// Search filters, named so a facet can leave its own filter out
+const (
+	filterTags   = "tags"
+	filterSource = "source"
+	filterAuthor = "author"
+	filterDate   = "date"
+)
+
+// dateBuckets are the publication date facet values, counted cumulatively
+var dateBuckets = []struct {
+	label string
+	age   time.Duration
+}{
+	{"Past 24 hours", 24 * time.Hour},
+	{"Past week", 7 * 24 * time.Hour},
+	{"Past month", 30 * 24 * time.Hour},
+	{"Past year", 365 * 24 * time.Hour},
+}
+
+// searchFilters narrow a search; apart from the query each is also a facet
+type searchFilters struct {
+	query  string
+	tags   []string
+	source string
+	author string
+	from   *time.Time
+	to     *time.Time
+}
+
+// where returns the WHERE clause matching the filters, leaving out the filter
+// named by skip, and its arguments. The query, when set, is always $1.
+func (f searchFilters) where(skip string) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.query != "" {
+		add("a.search_vector @@ websearch_to_tsquery('"+searchConfig+"', $%d)", f.query)
+	}
+	if len(f.tags) > 0 && skip != filterTags {
+		add(`EXISTS (
+			SELECT 1 FROM article_tags at JOIN tags t ON t.id = at.tag_id
+			WHERE at.article_id = a.id AND t.slug = ANY($%d)
+		)`, f.tags)
+	}
+	if f.source != "" && skip != filterSource {
+		add("a.source_name = $%d", f.source)
+	}
+	if f.author != "" && skip != filterAuthor {
+		add("a.author = $%d", f.author)
+	}
+	if skip != filterDate {
+		if f.from != nil {
+			add("COALESCE(a.published_at, a.created_at) >= $%d", *f.from)
+		}
+		if f.to != nil {
+			add("COALESCE(a.published_at, a.created_at) < $%d", *f.to)
+		}
+	}
+	return whereClause(conditions), args
+}
+
+// parseSearchDate parses a date or RFC 3339 timestamp. A bare date used as
+// an upper bound includes the whole day.
+func parseSearchDate(value string, upper bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// Search returns articles matching a full-text query and filters, along with
+// facet counts for narrowing the results further
+func Search(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.Query("page_size", "20"))
+	if pageSize < 1 || pageSize > 50 {
+		pageSize = 20
+	}
+	offset := (page - 1) * pageSize
+
+	filters := searchFilters{
+		query:  strings.TrimSpace(c.Query("q")),
+		source: c.Query("source"),
+		author: c.Query("author"),
+	}
+	if tags := c.Query("tags"); tags != "" {
+		filters.tags = strings.Split(tags, ",")
+	}
+
+	var err error
+	if filters.from, err = parseSearchDate(c.Query("from"), false); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid from date",
+			Message: "Use YYYY-MM-DD or an RFC 3339 timestamp",
+		})
+	}
+	if filters.to, err = parseSearchDate(c.Query("to"), true); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid to date",
+			Message: "Use YYYY-MM-DD or an RFC 3339 timestamp",
+		})
+	}
+
+	where, args := filters.where("")
+
+	var totalCount int
+	if err := database.Pool.QueryRow(ctx, `SELECT COUNT(DISTINCT `+storyKey+`) FROM articles a`+where, args...).Scan(&totalCount); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Failed to count search results",
+			Message: err.Error(),
+		})
+	}
+
+	orderBy := " ORDER BY a.published_at DESC NULLS LAST, a.created_at DESC"
+	if filters.query != "" && c.Query("sort_by", "relevance") == "relevance" {
+		orderBy = " ORDER BY ts_rank_cd(a.search_vector, websearch_to_tsquery('" + searchConfig + "', $1)) DESC," +
+			" a.published_at DESC NULLS LAST"
+	}
+
+	articles, err := articleRepo.List(ctx, `
+		SELECT `+repository.ArticleColumns+`
+		FROM `+collapseStories(where)+orderBy+fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2),
+		append(args, pageSize, offset)...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Failed to search articles",
+			Message: err.Error(),
+		})
+	}
+	if articles == nil {
+		articles = []models.Article{}
+	}
+
+	articles = enrichArticlesWithCoverage(ctx, articles)
+	articles = proxyArticleImages(articles)
+	if filters.query != "" {
+		articles = enrichArticlesWithHeadlines(ctx, articles, filters.query)
+	}
+	if userID, ok := middleware.GetUserID(c); ok {
+		articles = enrichArticlesWithUserData(ctx, articles, userID)
+	}
+
+	return c.JSON(models.SearchResponse{
+		Articles:   articles,
+		TotalCount: totalCount,
+		Page:       page,
+		PageSize:   pageSize,
+		HasMore:    totalCount > page*pageSize,
+		Facets:     searchFacets(ctx, filters),
+	})
+}
+
+// searchFacets counts the stories matching filters by tag, source, author
+// and publication date
+func searchFacets(ctx context.Context, filters searchFilters) models.SearchFacets {
+	facets := models.SearchFacets{Dates: []models.FacetCount{}}
+
+	where, args := filters.where(filterTags)
+	facets.Tags = countFacet(ctx, `
+		SELECT t.slug, t.name, COUNT(DISTINCT `+storyKey+`) AS stories
+		FROM articles a
+		JOIN article_tags at ON at.article_id = a.id
+		JOIN tags t ON t.id = at.tag_id`+where+`
+		GROUP BY t.slug, t.name
+		ORDER BY stories DESC, t.name`, args)
+
+	where, args = filters.where(filterSource)
+	facets.Sources = countFacet(ctx, `
+		SELECT a.source_name, a.source_name, COUNT(DISTINCT `+storyKey+`) AS stories
+		FROM articles a`+where+`
+		GROUP BY a.source_name
+		ORDER BY stories DESC, a.source_name`, args)
+
+	where, args = filters.where(filterAuthor)
+	facets.Authors = countFacet(ctx, `
+		SELECT a.author, a.author, COUNT(DISTINCT `+storyKey+`) AS stories
+		FROM articles a`+where+`
+		GROUP BY a.author
+		HAVING COALESCE(a.author, '') <> ''
+		ORDER BY stories DESC, a.author`, args)
+
+	// Bucket cutoffs are rounded so repeated searches share the same values
+	now := time.Now().UTC().Truncate(time.Minute)
+	where, args = filters.where(filterDate)
+	counts := make([]string, len(dateBuckets))
+	for i, bucket := range dateBuckets {
+		args = append(args, now.Add(-bucket.age))
+		counts[i] = fmt.Sprintf("COUNT(DISTINCT "+storyKey+") FILTER (WHERE COALESCE(a.published_at, a.created_at) >= $%d)", len(args))
+	}
+	dates := make([]int, len(dateBuckets))
+	dest := make([]interface{}, len(dates))
+	for i := range dates {
+		dest[i] = &dates[i]
+	}
+	err := database.Pool.QueryRow(ctx, `SELECT `+strings.Join(counts, ", ")+` FROM articles a`+where, args...).Scan(dest...)
+	if err == nil {
+		for i, bucket := range dateBuckets {
+			if dates[i] > 0 {
+				facets.Dates = append(facets.Dates, models.FacetCount{
+					Value: now.Add(-bucket.age).Format(time.RFC3339),
+					Label: bucket.label,
+					Count: dates[i],
+				})
+			}
+		}
+	}
+
+	return facets
+}
+
+// countFacet runs a query returning value, label and count rows and keeps
+// the first facetLimit of them
+func countFacet(ctx context.Context, query string, args []interface{}) []models.FacetCount {
+	counts := []models.FacetCount{}
+	rows, err := database.Pool.Query(ctx, query+fmt.Sprintf(" LIMIT %d", facetLimit), args...)
+	if err != nil {
+		return counts
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fc models.FacetCount
+		if err := rows.Scan(&fc.Value, &fc.Label, &fc.Count); err == nil {
+			counts = append(counts, fc)
+		}
+	}
+	return counts
+}
+
+// enrichArticlesWithHeadlines adds a snippet of each article's description
+// with the words matching search highlighted. Headlines are generated for
+// the page of results only, as ts_headline is expensive.
+func enrichArticlesWithHeadlines(ctx context.Context, articles []models.Article, search string) []models.Article {
+	if len(articles) == 0 {
+		return articles
+	}
+
+	ids := make([]uuid.UUID, len(articles))
+	for i, a := range articles {
+		ids[i] = a.ID
+	}
+
+	rows, err := database.Pool.Query(ctx, `
+		SELECT id, ts_headline('`+searchConfig+`', COALESCE(NULLIF(description, ''), title),
+			websearch_to_tsquery('`+searchConfig+`', $2),
+			'StartSel=`+highlightStart+`, StopSel=`+highlightStop+`, MinWords=15, MaxWords=35, MaxFragments=2, FragmentDelimiter=" ... "')
+		FROM articles
+		WHERE id = ANY($1)
+	`, ids, search)
+	if err != nil {
+		return articles
+	}
+	defer rows.Close()
+
+	headlines := make(map[uuid.UUID]string)
+	for rows.Next() {
+		var id uuid.UUID
+		var headline string
+		if err := rows.Scan(&id, &headline); err == nil {
+			headlines[id] = highlightHTML(headline)
+		}
+	}
+
+	for i := range articles {
+		if headline, ok := headlines[articles[i].ID]; ok {
+			articles[i].Headline = &headline
+		}
+	}
+	return articles
+}
+
+// highlightHTML escapes a ts_headline result for use as HTML, keeping only
+// the highlight markers as markup
+func highlightHTML(headline string) string {
+	var b strings.Builder
+	for i, part := range strings.Split(headline, highlightStart) {
+		match, rest, found := strings.Cut(part, highlightStop)
+		if i == 0 || !found {
+			// Text before the first match, or a marker that was in the text
+			if i > 0 {
+				part = highlightStart + part
+			}
+			b.WriteString(html.EscapeString(part))
+			continue
+		}
+		b.WriteString(highlightStart)
+		b.WriteString(html.EscapeString(match))
+		b.WriteString(highlightStop)
+		b.WriteString(html.EscapeString(rest))
+	}
+	return b.String()
+}
@@ -2,11 +2,16 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/zyyp/backend/internal/database"
 	"github.com/zyyp/backend/internal/models"
+	"github.com/zyyp/backend/pkg/rss"
 )
 
 // GetTags returns all tags
@@ -78,3 +83,179 @@ func GetPopularTags(c *fiber.Ctx) error {
 
 	return c.JSON(tags)
 }
+
+// GetTagRules returns the auto-tagging rules applied to ingested articles
+func GetTagRules(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := database.Pool.Query(ctx, `
+		SELECT r.id, r.tag_id, t.name, r.match_type, r.pattern, r.created_at
+		FROM tag_rules r
+		JOIN tags t ON t.id = r.tag_id
+		ORDER BY t.name ASC, r.created_at ASC
+	`)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "Failed to fetch tag rules",
+		})
+	}
+	defer rows.Close()
+
+	rules := []models.TagRule{}
+	for rows.Next() {
+		var r models.TagRule
+		if err := rows.Scan(&r.ID, &r.TagID, &r.TagName, &r.MatchType, &r.Pattern, &r.CreatedAt); err == nil {
+			rules = append(rules, r)
+		}
+	}
+
+	return c.JSON(rules)
+}
+
+// CreateTagRule adds a keyword or regex rule for a tag. It applies to
+// articles ingested from now on; use ApplyTagRules for existing ones.
+func CreateTagRule(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var req models.CreateTagRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid request body",
+		})
+	}
+
+	req.Pattern = strings.TrimSpace(req.Pattern)
+	if _, err := rss.CompileTagRule(req.MatchType, req.Pattern); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid tag rule",
+			Message: err.Error(),
+		})
+	}
+
+	var r models.TagRule
+	err := database.Pool.QueryRow(ctx, `
+		WITH inserted AS (
+			INSERT INTO tag_rules (tag_id, match_type, pattern)
+			VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING
+			RETURNING id, tag_id, match_type, pattern, created_at
+		)
+		SELECT i.id, i.tag_id, t.name, i.match_type, i.pattern, i.created_at
+		FROM inserted i
+		JOIN tags t ON t.id = i.tag_id
+	`, req.TagID, req.MatchType, req.Pattern).Scan(&r.ID, &r.TagID, &r.TagName, &r.MatchType, &r.Pattern, &r.CreatedAt)
+
+	if isNoRows(err) {
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: "Tag rule already exists",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Failed to create tag rule",
+			Message: err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.SuccessResponse{
+		Success: true,
+		Data:    r,
+		Message: "Tag rule created successfully",
+	})
+}
+
+// DeleteTagRule removes a tag rule. Tags it already added are kept.
+func DeleteTagRule(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ruleID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid rule ID",
+		})
+	}
+
+	tag, err := database.Pool.Exec(ctx, `DELETE FROM tag_rules WHERE id = $1`, ruleID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "Failed to delete tag rule",
+		})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: "Tag rule not found",
+		})
+	}
+
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Message: "Tag rule deleted successfully",
+	})
+}
+
+// ApplyTagRules re-runs the tag rules over all stored articles. The run
+// happens in the background, one at a time; its progress is available from
+// GetTagRulesRun.
+func ApplyTagRules(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	runID, err := rss.StartRetagRun(ctx)
+	if errors.Is(err, rss.ErrRetagRunning) {
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: "Tag rules are already being applied",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Failed to apply tag rules",
+			Message: err.Error(),
+		})
+	}
+
+	go func() {
+		// The run outlives the request, so it gets its own context
+		ctx, cancel := context.WithTimeout(context.Background(), rss.RetagTimeout)
+		defer cancel()
+
+		if err := rss.RetagArticles(ctx, runID); err != nil {
+			log.Printf("Tag rule run %s error: %v", runID, err)
+		}
+	}()
+
+	return c.Status(fiber.StatusAccepted).JSON(models.SuccessResponse{
+		Success: true,
+		Data:    map[string]interface{}{"run_id": runID},
+		Message: "Applying tag rules",
+	})
+}
+
+// GetTagRulesRun returns the progress of the latest run started by
+// ApplyTagRules
+func GetTagRulesRun(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var r models.RetagRun
+	err := database.Pool.QueryRow(ctx, `
+		SELECT id, status, started_at, finished_at, articles_scanned, tags_added, error
+		FROM tag_rule_runs
+		ORDER BY started_at DESC
+		LIMIT 1
+	`).Scan(&r.ID, &r.Status, &r.StartedAt, &r.FinishedAt, &r.ArticlesScanned, &r.TagsAdded, &r.Error)
+	if isNoRows(err) {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: "Tag rules have not been applied yet",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "Failed to fetch tag rule run",
+		})
+	}
+
+	return c.JSON(r)
+}
@@ -28,35 +28,93 @@ type Tag struct {
 
 // RSSSource represents an RSS feed source
 type RSSSource struct {
-	ID            uuid.UUID  `json:"id"`
-	Name          string     `json:"name"`
-	URL           string     `json:"url"`
-	FaviconURL    *string    `json:"favicon_url"`
-	Active        bool       `json:"active"`
-	LastFetchedAt *time.Time `json:"last_fetched_at"`
-	CreatedAt     time.Time  `json:"created_at"`
+	ID                  uuid.UUID     `json:"id"`
+	Name                string        `json:"name"`
+	URL                 string        `json:"url"`
+	FaviconURL          *string       `json:"favicon_url"`
+	Active              bool          `json:"active"`
+	LastFetchedAt       *time.Time    `json:"last_fetched_at"`
+	PollIntervalMinutes int           `json:"poll_interval_minutes"`
+	NextFetchAt         *time.Time    `json:"next_fetch_at"`
+	ConsecutiveFailures int           `json:"consecutive_failures"`
+	LastError           *string       `json:"last_error"`
+	LastStatusCode      *int          `json:"last_status_code"`
+	LastSuccessAt       *time.Time    `json:"last_success_at"`
+	CreatedAt           time.Time     `json:"created_at"`
+	Tags                []Tag         `json:"tags,omitempty"` // Added to every article from the source
+	Filters             SourceFilters `json:"filters"`
+	FetchFullContent    bool          `json:"fetch_full_content"`
+}
+
+// SourceFilters decide which items of a source are stored. If there are
+// include rules, an item must match one of them; items matching any exclude
+// rule are dropped.
+type SourceFilters struct {
+	Include []FilterRule `json:"include"`
+	Exclude []FilterRule `json:"exclude"`
+}
+
+// FilterRule matches feed items by keyword or regex on the title and body,
+// or by exact author or category name, ignoring case
+type FilterRule struct {
+	Field string `json:"field"` // "keyword", "regex", "author" or "category"
+	Value string `json:"value"`
+}
+
+// FetchRun is the ingestion report of one cron or manual RSS fetch run
+type FetchRun struct {
+	ID                uuid.UUID       `json:"id"`
+	Trigger           string          `json:"trigger"` // "cron" or "manual"
+	Status            string          `json:"status"`  // "running", "completed" or "failed"
+	StartedAt         time.Time       `json:"started_at"`
+	FinishedAt        *time.Time      `json:"finished_at"`
+	SourcesAttempted  int             `json:"sources_attempted"`
+	ItemsSeen         int             `json:"items_seen"`
+	ItemsFiltered     int             `json:"items_filtered"`
+	ArticlesInserted  int             `json:"articles_inserted"`
+	ArticlesUpdated   int             `json:"articles_updated"`
+	DuplicatesSkipped int             `json:"duplicates_skipped"`
+	Errors            []FetchRunError `json:"errors"`
+	Error             *string         `json:"error"`
+}
+
+// FetchRunError is a fetch error for one source within a run
+type FetchRunError struct {
+	SourceID   uuid.UUID `json:"source_id"`
+	SourceName string    `json:"source_name"`
+	Error      string    `json:"error"`
 }
 
 // Article represents a content article
 type Article struct {
-	ID                 uuid.UUID  `json:"id"`
-	Title              string     `json:"title"`
-	URL                string     `json:"url"`
-	Description        *string    `json:"description"`
-	Content            *string    `json:"content,omitempty"`
-	Author             *string    `json:"author"`
-	PublishedAt        *time.Time `json:"published_at"`
-	SourceID           *uuid.UUID `json:"source_id"`
-	SourceName         string     `json:"source_name"`
-	ImageURL           *string    `json:"image_url"`
-	ReadingTimeMinutes int        `json:"reading_time_minutes"`
-	Upvotes            int        `json:"upvotes"`
-	Downvotes          int        `json:"downvotes"`
-	CreatedAt          time.Time  `json:"created_at"`
-	UpdatedAt          time.Time  `json:"updated_at"`
-	Tags               []Tag      `json:"tags,omitempty"`
-	IsBookmarked       bool       `json:"is_bookmarked,omitempty"`
-	UserVote           *string    `json:"user_vote,omitempty"` // "up", "down", or nil
+	ID                 uuid.UUID         `json:"id"`
+	Title              string            `json:"title"`
+	URL                string            `json:"url"`
+	Description        *string           `json:"description"`
+	Content            *string           `json:"content,omitempty"`
+	Author             *string           `json:"author"`
+	PublishedAt        *time.Time        `json:"published_at"`
+	SourceID           *uuid.UUID        `json:"source_id"`
+	SourceName         string            `json:"source_name"`
+	FaviconURL         *string           `json:"favicon_url"`
+	ImageURL           *string           `json:"image_url"`
+	ReadingTimeMinutes int               `json:"reading_time_minutes"`
+	Upvotes            int               `json:"upvotes"`
+	Downvotes          int               `json:"downvotes"`
+	CreatedAt          time.Time         `json:"created_at"`
+	UpdatedAt          time.Time         `json:"updated_at"`
+	Tags               []Tag             `json:"tags,omitempty"`
+	IsBookmarked       bool              `json:"is_bookmarked,omitempty"`
+	UserVote           *string           `json:"user_vote,omitempty"` // "up", "down", or nil
+	AlsoCoveredBy      []ArticleCoverage `json:"also_covered_by,omitempty"`
+	Headline           *string           `json:"headline,omitempty"` // Search snippet, matches wrapped in <mark>
+}
+
+// ArticleCoverage is a near-duplicate of an article published by another source
+type ArticleCoverage struct {
+	ArticleID  uuid.UUID `json:"article_id"`
+	SourceName string    `json:"source_name"`
+	URL        string    `json:"url"`
 }
 
 // Bookmark represents a user's bookmark
@@ -87,18 +145,48 @@ type ReadingHistory struct {
 
 // API Request/Response types
 
+// ArticlesResponse is a page of articles. NextCursor fetches the following
+// page.
 type ArticlesResponse struct {
 	Articles   []Article `json:"articles"`
 	TotalCount int       `json:"total_count"`
 	Page       int       `json:"page"`
 	PageSize   int       `json:"page_size"`
 	HasMore    bool      `json:"has_more"`
+	NextCursor *string   `json:"next_cursor"`
+}
+
+type SearchResponse struct {
+	Articles   []Article    `json:"articles"`
+	TotalCount int          `json:"total_count"`
+	Page       int          `json:"page"`
+	PageSize   int          `json:"page_size"`
+	HasMore    bool         `json:"has_more"`
+	Facets     SearchFacets `json:"facets"`
+}
+
+// SearchFacets counts the matching articles per value of each search filter.
+// Each facet ignores its own filter, so the alternatives stay visible.
+type SearchFacets struct {
+	Tags    []FacetCount `json:"tags"`
+	Sources []FacetCount `json:"sources"`
+	Authors []FacetCount `json:"authors"`
+	Dates   []FacetCount `json:"dates"`
+}
+
+// FacetCount is one facet value. Value is what to pass back as the filter:
+// a tag slug, source name, author, or a "from" timestamp for date buckets.
+type FacetCount struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+	Count int    `json:"count"`
 }
 
 type ArticleFilters struct {
 	Tags     []string `query:"tags"`
 	Search   string   `query:"search"`
-	SortBy   string   `query:"sort_by"`   // "newest", "popular", "trending"
+	SortBy   string   `query:"sort_by"`   // "newest", "popular", "trending", "relevance"
+	Cursor   string   `query:"cursor"`
 	Page     int      `query:"page"`
 	PageSize int      `query:"page_size"`
 }
@@ -130,9 +218,93 @@ type CreateArticleRequest struct {
 }
 
 type CreateRSSSourceRequest struct {
-	Name       string  `json:"name"`
-	URL        string  `json:"url"`
-	FaviconURL *string `json:"favicon_url"`
+	Name             string         `json:"name"`
+	URL              string         `json:"url"`
+	FaviconURL       *string        `json:"favicon_url"`
+	TagIDs           []uuid.UUID    `json:"tag_ids"`
+	Filters          *SourceFilters `json:"filters"` // Nil keeps every item
+	FetchFullContent bool           `json:"fetch_full_content"`
+}
+
+// UpdateRSSSourceRequest holds the source fields to change; nil fields are
+// left as they are
+type UpdateRSSSourceRequest struct {
+	Name             *string        `json:"name"`
+	URL              *string        `json:"url"`
+	FaviconURL       *string        `json:"favicon_url"` // Empty string clears it
+	Active           *bool          `json:"active"`
+	TagIDs           *[]uuid.UUID   `json:"tag_ids"`
+	Filters          *SourceFilters `json:"filters"`
+	FetchFullContent *bool          `json:"fetch_full_content"`
+}
+
+type PreviewRSSSourceRequest struct {
+	URL string `json:"url"`
+}
+
+// FeedPreview is the dry-run result of fetching a feed
+type FeedPreview struct {
+	FeedURL   string           `json:"feed_url"`
+	FeedTitle string           `json:"feed_title"`
+	Articles  []PreviewArticle `json:"articles"`
+}
+
+// PreviewArticle is a feed item as it would be stored by an RSS fetch
+type PreviewArticle struct {
+	Title              string     `json:"title"`
+	URL                string     `json:"url"`
+	Description        string     `json:"description"`
+	Author             *string    `json:"author"`
+	PublishedAt        *time.Time `json:"published_at"`
+	ImageURL           *string    `json:"image_url"`
+	ReadingTimeMinutes int        `json:"reading_time_minutes"`
+	Tags               []Tag      `json:"tags"`
+	Duplicate          bool       `json:"duplicate"` // Would be skipped as already stored
+}
+
+// TagRule tags articles whose title or body matches a keyword or regex
+type TagRule struct {
+	ID        uuid.UUID `json:"id"`
+	TagID     uuid.UUID `json:"tag_id"`
+	TagName   string    `json:"tag_name"`
+	MatchType string    `json:"match_type"` // "keyword" or "regex"
+	Pattern   string    `json:"pattern"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateTagRuleRequest struct {
+	TagID     uuid.UUID `json:"tag_id"`
+	MatchType string    `json:"match_type"`
+	Pattern   string    `json:"pattern"`
+}
+
+// RetagResult reports a run of the tag rules over stored articles
+type RetagResult struct {
+	ArticlesScanned int `json:"articles_scanned"`
+	TagsAdded       int `json:"tags_added"`
+}
+
+// RetagRun is the progress of a background run of the tag rules
+type RetagRun struct {
+	ID         uuid.UUID  `json:"id"`
+	Status     string     `json:"status"` // running, completed or failed
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	Error      *string    `json:"error"`
+	RetagResult
+}
+
+type OPMLImportResult struct {
+	Created []OPMLImportedSource `json:"created"`
+	Skipped []string             `json:"skipped"` // URLs that already exist
+	Errors  []string             `json:"errors"`
+}
+
+type OPMLImportedSource struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	URL  string    `json:"url"`
+	Tags []string  `json:"tags"`
 }
 
 type ErrorResponse struct {
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/zyyp/backend/internal/models"
+)
+
+// ArticleColumns are the columns selected for article listings, in the order
+// ScanArticle reads them. Queries must alias articles as a.
+const ArticleColumns = `a.id, a.title, a.url, a.description, a.author,
+	a.published_at, a.source_id, a.source_name, a.image_url,
+	a.reading_time_minutes, a.upvotes, a.downvotes, a.created_at, a.updated_at,
+	(SELECT s.favicon_url FROM rss_sources s WHERE s.id = a.source_id)`
+
+// Querier is the part of pgxpool.Pool the repository needs
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+// Articles loads articles and their related data for the listing handlers,
+// batching the per-article lookups so a page costs a fixed number of
+// queries whatever its size
+type Articles struct {
+	db Querier
+}
+
+func NewArticles(db Querier) *Articles {
+	return &Articles{db: db}
+}
+
+// ScanArticle reads a row selected with ArticleColumns, followed by any
+// extra columns into extra
+func ScanArticle(rows pgx.Rows, extra ...interface{}) (models.Article, error) {
+	var a models.Article
+	dest := append([]interface{}{
+		&a.ID, &a.Title, &a.URL, &a.Description, &a.Author,
+		&a.PublishedAt, &a.SourceID, &a.SourceName, &a.ImageURL,
+		&a.ReadingTimeMinutes, &a.Upvotes, &a.Downvotes, &a.CreatedAt, &a.UpdatedAt,
+		&a.FaviconURL,
+	}, extra...)
+	err := rows.Scan(dest...)
+	return a, err
+}
+
+// List runs a query selecting ArticleColumns and loads the tags of the
+// articles it returns
+func (r *Articles) List(ctx context.Context, query string, args ...interface{}) ([]models.Article, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var articles []models.Article
+	for rows.Next() {
+		a, err := ScanArticle(rows)
+		if err != nil {
+			continue
+		}
+		articles = append(articles, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.LoadTags(ctx, articles); err != nil {
+		return nil, err
+	}
+	return articles, nil
+}
+
+// LoadTags sets the tags of all articles with a single query
+func (r *Articles) LoadTags(ctx context.Context, articles []models.Article) error {
+	if len(articles) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(articles))
+	for i, a := range articles {
+		ids[i] = a.ID
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT at.article_id, t.id, t.name, t.slug, t.color, t.created_at
+		FROM article_tags at
+		JOIN tags t ON t.id = at.tag_id
+		WHERE at.article_id = ANY($1)
+		ORDER BY t.name
+	`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	tags := make(map[uuid.UUID][]models.Tag)
+	for rows.Next() {
+		var articleID uuid.UUID
+		var t models.Tag
+		if err := rows.Scan(&articleID, &t.ID, &t.Name, &t.Slug, &t.Color, &t.CreatedAt); err == nil {
+			tags[articleID] = append(tags[articleID], t)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range articles {
+		articles[i].Tags = tags[articles[i].ID]
+	}
+	return nil
+}
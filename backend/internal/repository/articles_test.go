@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeDB answers listing queries with generated articles and tag queries
+// with two tags per article, counting the round trips
+type fakeDB struct {
+	ids     []uuid.UUID
+	queries int
+}
+
+func newFakeDB(articles int) *fakeDB {
+	db := &fakeDB{}
+	for i := 0; i < articles; i++ {
+		db.ids = append(db.ids, uuid.New())
+	}
+	return db
+}
+
+func (db *fakeDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	db.queries++
+	now := time.Now()
+
+	var rows [][]interface{}
+	if strings.Contains(sql, "article_tags") {
+		for _, id := range args[0].([]uuid.UUID) {
+			for j := 0; j < 2; j++ {
+				name := fmt.Sprintf("tag-%d", j)
+				rows = append(rows, []interface{}{id, uuid.New(), name, name, "#000000", now})
+			}
+		}
+	} else {
+		for i, id := range db.ids {
+			rows = append(rows, []interface{}{
+				id, fmt.Sprintf("Article %d", i), fmt.Sprintf("https://example.com/%d", i), nil, nil,
+				nil, nil, "Example", nil,
+				3, 0, 0, now, now,
+				nil,
+			})
+		}
+	}
+	return &fakeRows{rows: rows, pos: -1}, nil
+}
+
+type fakeRows struct {
+	rows [][]interface{}
+	pos  int
+}
+
+func (r *fakeRows) Close()                                       {}
+func (r *fakeRows) Err() error                                   { return nil }
+func (r *fakeRows) CommandTag() pgconn.CommandTag                { return pgconn.CommandTag{} }
+func (r *fakeRows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+func (r *fakeRows) Values() ([]any, error)                       { return r.rows[r.pos], nil }
+func (r *fakeRows) RawValues() [][]byte                          { return nil }
+func (r *fakeRows) Conn() *pgx.Conn                              { return nil }
+
+func (r *fakeRows) Next() bool {
+	r.pos++
+	return r.pos < len(r.rows)
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	row := r.rows[r.pos]
+	if len(dest) != len(row) {
+		return fmt.Errorf("scanning %d columns into %d values", len(row), len(dest))
+	}
+	for i, v := range row {
+		if v != nil {
+			reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(v))
+		}
+	}
+	return nil
+}
+
+func TestListQueryCount(t *testing.T) {
+	for _, size := range []int{1, 20, 50} {
+		db := newFakeDB(size)
+		articles, err := NewArticles(db).List(context.Background(), "SELECT "+ArticleColumns+" FROM articles a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(articles) != size {
+			t.Fatalf("expected %d articles, got %d", size, len(articles))
+		}
+		for _, a := range articles {
+			if len(a.Tags) != 2 {
+				t.Fatalf("expected 2 tags on article %s, got %d", a.ID, len(a.Tags))
+			}
+		}
+		if db.queries != 2 {
+			t.Errorf("listing %d articles took %d queries, want 2", size, db.queries)
+		}
+	}
+}
+
+func TestLoadTagsWithoutArticles(t *testing.T) {
+	db := newFakeDB(0)
+	if err := NewArticles(db).LoadTags(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if db.queries != 0 {
+		t.Errorf("expected no queries, got %d", db.queries)
+	}
+}
+
+func BenchmarkList(b *testing.B) {
+	db := newFakeDB(50)
+	repo := NewArticles(db)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.List(ctx, "SELECT "+ArticleColumns+" FROM articles a"); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(db.queries)/float64(b.N), "queries/op")
+}
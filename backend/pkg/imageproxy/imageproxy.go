@@ -0,0 +1,359 @@
+package imageproxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	// Decoders for the formats accepted from publishers
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/HugoSmits86/nativewebp"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+
+	"github.com/zyyp/backend/internal/database"
+	"github.com/zyyp/backend/pkg/safehttp"
+)
+
+// Widths are the thumbnail widths served, in pixels. The smallest ones are
+// meant for favicons.
+var Widths = []int{32, 64, 160, 320, 640, 1280}
+
+// DefaultWidth is served when no width is requested
+const DefaultWidth = 640
+
+const (
+	jpegQuality = 80
+
+	// maxPixels guards against decompression bombs
+	maxPixels = 40_000_000
+)
+
+var (
+	ErrInvalidHash  = errors.New("imageproxy: invalid image hash")
+	ErrUnknownImage = errors.New("imageproxy: unknown image")
+	ErrNotAnImage   = errors.New("imageproxy: URL is not a supported image")
+	ErrTooLarge     = errors.New("imageproxy: image is too large")
+)
+
+var hashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// allowedTypes are the image content types the proxy accepts
+var allowedTypes = map[string]bool{
+	"image/jpeg":               true,
+	"image/png":                true,
+	"image/gif":                true,
+	"image/webp":               true,
+	"image/x-icon":             true,
+	"image/vnd.microsoft.icon": true,
+}
+
+// iconType is the sniffed content type of .ico files, which cannot be
+// decoded and are served as they are
+const iconType = "image/x-icon"
+
+// Proxy fetches publisher images and favicons once and serves resized
+// thumbnails from storage, as WebP to clients that accept it and JPEG
+// otherwise. The WebP encoder is lossless, so a WebP thumbnail that comes
+// out larger than the JPEG one is stored as JPEG instead.
+type Proxy struct {
+	client   *http.Client
+	storage  Storage
+	maxBytes int64
+	basePath string
+}
+
+type Options struct {
+	Client   *http.Client // Client used to fetch originals
+	Storage  Storage
+	MaxBytes int64  // Largest original accepted
+	BasePath string // Path the proxy is mounted at, e.g. "/api/images"
+}
+
+func New(opts Options) *Proxy {
+	if opts.Client == nil {
+		opts.Client = safehttp.NewClient(safehttp.Options{Timeout: 15 * time.Second})
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 10 << 20
+	}
+	return &Proxy{
+		client:   opts.Client,
+		storage:  opts.Storage,
+		maxBytes: opts.MaxBytes,
+		basePath: strings.TrimRight(opts.BasePath, "/"),
+	}
+}
+
+// Hash returns the key an image URL is proxied under
+func Hash(rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return hex.EncodeToString(sum[:])
+}
+
+// URL returns the proxied URL for an original image URL. Originals must be
+// registered with Register before the proxied URL can be served.
+func (p *Proxy) URL(rawURL string) string {
+	return p.basePath + "/" + Hash(rawURL)
+}
+
+// RegisterURLs registers the set URLs among urls, logging any error. It
+// does nothing on a nil Proxy, so callers need not check whether images are
+// proxied.
+func (p *Proxy) RegisterURLs(ctx context.Context, urls ...*string) {
+	if p == nil {
+		return
+	}
+	var list []string
+	for _, u := range urls {
+		if u != nil && *u != "" {
+			list = append(list, *u)
+		}
+	}
+	if err := p.Register(ctx, list); err != nil {
+		log.Printf("Error registering images: %v", err)
+	}
+}
+
+// Register records original image URLs so that their hashes can be served
+func (p *Proxy) Register(ctx context.Context, urls []string) error {
+	if len(urls) == 0 {
+		return nil
+	}
+	hashes := make([]string, len(urls))
+	for i, u := range urls {
+		hashes[i] = Hash(u)
+	}
+	_, err := database.Pool.Exec(ctx, `
+		INSERT INTO images (hash, url)
+		SELECT * FROM unnest($1::text[], $2::text[])
+		ON CONFLICT (hash) DO NOTHING
+	`, hashes, urls)
+	return err
+}
+
+// Image returns the thumbnail of the image registered under hash, at the
+// smallest fixed width that is at least width, and its content type. With
+// webp set the thumbnail may be WebP, otherwise it is JPEG. The original is
+// downloaded and the thumbnail generated on first request. Icons are
+// returned as downloaded.
+func (p *Proxy) Image(ctx context.Context, hash string, width int, webp bool) ([]byte, string, error) {
+	if !hashPattern.MatchString(hash) {
+		return nil, "", ErrInvalidHash
+	}
+	width = snapWidth(width)
+
+	key := fmt.Sprintf("%s/%d.jpg", hash, width)
+	if webp {
+		key = fmt.Sprintf("%s/%d.webp", hash, width)
+	}
+	if data, err := p.storage.Get(ctx, key); err == nil {
+		// WebP thumbnails may have been stored as JPEG
+		return data, http.DetectContentType(data), nil
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, "", err
+	}
+
+	original, err := p.original(ctx, hash)
+	if err != nil {
+		return nil, "", err
+	}
+	if http.DetectContentType(original) == iconType {
+		return original, iconType, nil
+	}
+
+	data, err := thumbnail(original, width, webp)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := p.storage.Put(ctx, key, data); err != nil {
+		return nil, "", err
+	}
+	return data, http.DetectContentType(data), nil
+}
+
+// original returns the stored original for hash, downloading it first if
+// needed
+func (p *Proxy) original(ctx context.Context, hash string) ([]byte, error) {
+	key := hash + "/original"
+	if data, err := p.storage.Get(ctx, key); err == nil {
+		return data, nil
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	var rawURL string
+	var lastError *string
+	err := database.Pool.QueryRow(ctx, `SELECT url, error FROM images WHERE hash = $1`, hash).Scan(&rawURL, &lastError)
+	if err != nil {
+		return nil, ErrUnknownImage
+	}
+	// Originals that failed validation are not downloaded again
+	if lastError != nil {
+		return nil, ErrNotAnImage
+	}
+
+	data, contentType, err := p.download(ctx, rawURL)
+	if err != nil {
+		if errors.Is(err, ErrNotAnImage) || errors.Is(err, ErrTooLarge) {
+			msg := err.Error()
+			_, _ = database.Pool.Exec(ctx, `UPDATE images SET error = $2 WHERE hash = $1`, hash, msg)
+		}
+		return nil, err
+	}
+
+	if err := p.storage.Put(ctx, key, data); err != nil {
+		return nil, err
+	}
+	_, _ = database.Pool.Exec(ctx, `
+		UPDATE images SET content_type = $2, size_bytes = $3, fetched_at = NOW() WHERE hash = $1
+	`, hash, contentType, len(data))
+	return data, nil
+}
+
+// download fetches an original, checking its declared and sniffed content
+// type and its size
+func (p *Proxy) download(ctx context.Context, rawURL string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", "image/webp,image/jpeg,image/png,image/gif;q=0.8")
+
+	resp, err := p.client.Do(req)
+	if errors.Is(err, safehttp.ErrBodyTooLarge) {
+		return nil, "", ErrTooLarge
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, "", fmt.Errorf("imageproxy: original returned %s", resp.Status)
+	}
+	if resp.ContentLength > p.maxBytes {
+		return nil, "", ErrTooLarge
+	}
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && !allowedTypes[mediaType] {
+		return nil, "", ErrNotAnImage
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, p.maxBytes+1))
+	if errors.Is(err, safehttp.ErrBodyTooLarge) {
+		return nil, "", ErrTooLarge
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) > p.maxBytes {
+		return nil, "", ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	if !allowedTypes[contentType] {
+		return nil, "", ErrNotAnImage
+	}
+	return data, contentType, nil
+}
+
+// thumbnail encodes a copy of an image no wider than width as JPEG or, with
+// webp set, as WebP when that is smaller
+func thumbnail(data []byte, width int, webp bool) ([]byte, error) {
+	img, err := resize(data, width)
+	if err != nil {
+		return nil, err
+	}
+
+	// JPEG has no transparency, so it is flattened onto white
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, image.Point{}, draw.Over)
+
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, flat, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+	if !webp {
+		return jpg.Bytes(), nil
+	}
+
+	lossless, err := encodeWebP(img)
+	if err != nil || len(lossless) >= jpg.Len() {
+		return jpg.Bytes(), nil
+	}
+	return lossless, nil
+}
+
+// encodeWebP encodes an image as lossless WebP. The encoder panics on some
+// images with many colours, which is returned as an error.
+func encodeWebP(img image.Image) (data []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("imageproxy: encoding WebP: %v", r)
+		}
+	}()
+	var buf bytes.Buffer
+	if err := nativewebp.Encode(&buf, img, nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// resize decodes an image and scales it to no wider than width
+func resize(data []byte, width int) (*image.RGBA, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrNotAnImage
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrNotAnImage
+	}
+
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w > width {
+		h = h * width / w
+		w = width
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+	return dst, nil
+}
+
+// snapWidth returns the smallest fixed width that is at least width
+func snapWidth(width int) int {
+	if width <= 0 {
+		return DefaultWidth
+	}
+	for _, w := range Widths {
+		if w >= width {
+			return w
+		}
+	}
+	return Widths[len(Widths)-1]
+}
@@ -0,0 +1,94 @@
+package imageproxy
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"net/http"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// icon is a flat logo on a transparent background, which lossless WebP
+// compresses better than JPEG
+func icon(t *testing.T) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, 128, 128))
+	for y := 32; y < 96; y++ {
+		for x := 32; x < 96; x++ {
+			img.Set(x, y, color.NRGBA{R: 0, G: 173, B: 216, A: 255})
+		}
+	}
+	return encodePNG(t, img)
+}
+
+// photo is noise, which only lossy formats compress well
+func photo(t *testing.T) []byte {
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	for i := range img.Pix {
+		img.Pix[i] = byte(rng.Intn(256))
+	}
+	return encodePNG(t, img)
+}
+
+func TestThumbnail(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  []byte
+		width int
+		webp  bool
+		want  string
+	}{
+		{"JPEG unless WebP is accepted", icon(t), 64, false, "image/jpeg"},
+		{"WebP when smaller", icon(t), 64, true, "image/webp"},
+		{"JPEG when WebP is larger", photo(t), 320, true, "image/jpeg"},
+	}
+	for _, tt := range tests {
+		data, err := thumbnail(tt.data, tt.width, tt.webp)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := http.DetectContentType(data); got != tt.want {
+			t.Errorf("%s: content type %s, want %s", tt.name, got, tt.want)
+		}
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: decoding thumbnail: %v", tt.name, err)
+		}
+		if cfg.Width != tt.width {
+			t.Errorf("%s: width %d, want %d", tt.name, cfg.Width, tt.width)
+		}
+	}
+}
+
+func TestThumbnailNotAnImage(t *testing.T) {
+	if _, err := thumbnail([]byte("<html></html>"), 64, true); err != ErrNotAnImage {
+		t.Errorf("expected ErrNotAnImage, got %v", err)
+	}
+}
+
+func TestSnapWidth(t *testing.T) {
+	tests := []struct{ width, want int }{
+		{0, DefaultWidth},
+		{-5, DefaultWidth},
+		{1, 32},
+		{64, 64},
+		{100, 160},
+		{5000, 1280},
+	}
+	for _, tt := range tests {
+		if got := snapWidth(tt.width); got != tt.want {
+			t.Errorf("snapWidth(%d) = %d, want %d", tt.width, got, tt.want)
+		}
+	}
+}
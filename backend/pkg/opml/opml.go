@@ -0,0 +1,154 @@
+package opml
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// OPML is an OPML 2.0 document
+type OPML struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    Head     `xml:"head"`
+	Body    Body     `xml:"body"`
+}
+
+type Head struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type Body struct {
+	Outlines []Outline `xml:"outline"`
+}
+
+// Outline is a feed subscription or, when it has no xmlUrl, a folder of
+// further outlines
+type Outline struct {
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr,omitempty"`
+	Type     string    `xml:"type,attr,omitempty"`
+	XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string    `xml:"htmlUrl,attr,omitempty"`
+	Category string    `xml:"category,attr,omitempty"`
+	Outlines []Outline `xml:"outline,omitempty"`
+}
+
+// Feed is a flattened feed subscription from an OPML document
+type Feed struct {
+	Name       string
+	URL        string
+	SiteURL    string
+	Categories []string
+}
+
+var ErrNoFeeds = errors.New("opml: document contains no feeds")
+
+// Parse reads an OPML document and returns every feed it contains. A feed's
+// categories are the titles of the folders it is nested in plus the entries
+// of its category attribute.
+func Parse(r io.Reader) ([]Feed, error) {
+	var doc OPML
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var feeds []Feed
+	collect(doc.Body.Outlines, nil, &feeds)
+	if len(feeds) == 0 {
+		return nil, ErrNoFeeds
+	}
+	return feeds, nil
+}
+
+func collect(outlines []Outline, folders []string, feeds *[]Feed) {
+	for _, o := range outlines {
+		name := strings.TrimSpace(o.Title)
+		if name == "" {
+			name = strings.TrimSpace(o.Text)
+		}
+
+		if o.XMLURL == "" {
+			// Folder outline
+			nested := folders
+			if name != "" {
+				nested = append(append([]string{}, folders...), name)
+			}
+			collect(o.Outlines, nested, feeds)
+			continue
+		}
+
+		categories := append([]string{}, folders...)
+		categories = append(categories, parseCategories(o.Category)...)
+
+		*feeds = append(*feeds, Feed{
+			Name:       name,
+			URL:        strings.TrimSpace(o.XMLURL),
+			SiteURL:    strings.TrimSpace(o.HTMLURL),
+			Categories: dedupe(categories),
+		})
+	}
+}
+
+// parseCategories splits a category attribute. Entries are comma separated
+// and may be slash-delimited paths, of which only the last segment is used.
+func parseCategories(attr string) []string {
+	var categories []string
+	for _, entry := range strings.Split(attr, ",") {
+		segments := strings.Split(strings.TrimSpace(entry), "/")
+		if last := strings.TrimSpace(segments[len(segments)-1]); last != "" {
+			categories = append(categories, last)
+		}
+	}
+	return categories
+}
+
+func dedupe(values []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, v := range values {
+		key := strings.ToLower(v)
+		if !seen[key] {
+			seen[key] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// Write encodes feeds as an OPML 2.0 document. Categories are written to the
+// category attribute so they survive a round trip through Parse.
+func Write(w io.Writer, title string, feeds []Feed) error {
+	doc := OPML{
+		Version: "2.0",
+		Head: Head{
+			Title:       title,
+			DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+		},
+	}
+	for _, f := range feeds {
+		doc.Body.Outlines = append(doc.Body.Outlines, Outline{
+			Text:     f.Name,
+			Title:    f.Name,
+			Type:     "rss",
+			XMLURL:   f.URL,
+			HTMLURL:  f.SiteURL,
+			Category: strings.Join(f.Categories, ","),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(doc)
+}
@@ -0,0 +1,96 @@
+package opml
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const nested = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<opml version="2.0">
+  <head><title>Subscriptions</title></head>
+  <body>
+    <outline text="Unfiled" xmlUrl=" https://example.com/feed.xml " htmlUrl="https://example.com/"/>
+    <outline text="Tech">
+      <outline text="Go blog" title="The Go Blog" type="rss" xmlUrl="https://go.dev/blog/feed.atom"/>
+      <outline title="Languages">
+        <outline text="Rust" xmlUrl="https://blog.rust-lang.org/feed.xml" category="/Programming/Systems, rust,tech"/>
+      </outline>
+    </outline>
+    <outline text="">
+      <outline text="Untitled folder feed" xmlUrl="https://example.org/rss"/>
+    </outline>
+  </body>
+</opml>`
+
+func TestParseNestedOutlines(t *testing.T) {
+	feeds, err := Parse(strings.NewReader(nested))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Feed{
+		{Name: "Unfiled", URL: "https://example.com/feed.xml", SiteURL: "https://example.com/"},
+		{Name: "The Go Blog", URL: "https://go.dev/blog/feed.atom", Categories: []string{"Tech"}},
+		// Category paths keep their last segment, and duplicates are
+		// dropped case-insensitively, keeping the first spelling
+		{Name: "Rust", URL: "https://blog.rust-lang.org/feed.xml", Categories: []string{"Tech", "Languages", "Systems", "rust"}},
+		{Name: "Untitled folder feed", URL: "https://example.org/rss"},
+	}
+	if !reflect.DeepEqual(feeds, want) {
+		t.Errorf("Parse returned\n%+v\nwant\n%+v", feeds, want)
+	}
+}
+
+func TestParseCategories(t *testing.T) {
+	tests := []struct {
+		attr string
+		want []string
+	}{
+		{"", nil},
+		{"go", []string{"go"}},
+		{"go, rust ,", []string{"go", "rust"}},
+		{"/Tech/Go", []string{"Go"}},
+		{"Tech/", nil},
+		{"/Tech/Go,News", []string{"Go", "News"}},
+	}
+	for _, tt := range tests {
+		if got := parseCategories(tt.attr); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseCategories(%q) = %q, want %q", tt.attr, got, tt.want)
+		}
+	}
+}
+
+func TestParseWithoutFeeds(t *testing.T) {
+	doc := `<opml version="2.0"><body><outline text="Empty folder"><outline text="Note"/></outline></body></opml>`
+	if _, err := Parse(strings.NewReader(doc)); !errors.Is(err, ErrNoFeeds) {
+		t.Fatalf("expected ErrNoFeeds, got %v", err)
+	}
+}
+
+func TestParseInvalidDocument(t *testing.T) {
+	if _, err := Parse(strings.NewReader("not xml")); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	feeds := []Feed{
+		{Name: "Go & friends", URL: "https://go.dev/blog/feed.atom?a=1&b=2", SiteURL: "https://go.dev/blog", Categories: []string{"Go", "Programming"}},
+		{Name: "No tags", URL: "https://example.com/feed.xml"},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "Export", feeds); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, feeds) {
+		t.Errorf("round trip returned\n%+v\nwant\n%+v", parsed, feeds)
+	}
+}
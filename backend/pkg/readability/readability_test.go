@@ -0,0 +1,70 @@
+package readability
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func extractFixture(t *testing.T, name string) (string, error) {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Extract(body)
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []string
+		notWant []string
+	}{
+		{
+			fixture: "blog.html",
+			want:    []string{"local run queue", "Preemption, added in Go 1.14", "runtime.GOMAXPROCS(4)"},
+			notWant: []string{"Archive", "newsletter", "Great article", "Copyright", "analytics", "font-family"},
+		},
+		{
+			// The link list has more commas, but its text is all links
+			fixture: "linkfarm.html",
+			want:    []string{"Release engineering", "incidents went down"},
+			notWant: []string{"faster builds"},
+		},
+		{
+			// Without scored paragraphs, the article element is used
+			fixture: "article.html",
+			want:    []string{"Notes from the conference", "hallway track"},
+			notWant: []string{"Home"},
+		},
+	}
+	for _, tt := range tests {
+		content, err := extractFixture(t, tt.fixture)
+		if err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		for _, s := range tt.want {
+			if !strings.Contains(content, s) {
+				t.Errorf("%s: expected %q in\n%s", tt.fixture, s, content)
+			}
+		}
+		for _, s := range tt.notWant {
+			if strings.Contains(content, s) {
+				t.Errorf("%s: unexpected %q in\n%s", tt.fixture, s, content)
+			}
+		}
+	}
+}
+
+func TestExtractTooShort(t *testing.T) {
+	if _, err := extractFixture(t, "short.html"); !errors.Is(err, ErrNoContent) {
+		t.Fatalf("expected ErrNoContent, got %v", err)
+	}
+	if _, err := Extract(nil); !errors.Is(err, ErrNoContent) {
+		t.Fatalf("expected ErrNoContent for an empty page, got %v", err)
+	}
+}
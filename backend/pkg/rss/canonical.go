@@ -0,0 +1,42 @@
+package rss
+
+import (
+	"context"
+
+	"github.com/zyyp/backend/pkg/urlnorm"
+)
+
+// CanonicalURL returns the dedupe key for an article link. When redirect
+// resolution is enabled the page is fetched first, so FeedBurner-style
+// redirects and <link rel=canonical> are honoured. Links that cannot be
+// canonicalized are returned unchanged.
+func (s *Service) CanonicalURL(ctx context.Context, rawURL string) string {
+	if s.resolveCanonical {
+		if resolved, err := s.resolveURL(ctx, rawURL); err == nil {
+			rawURL = resolved
+		}
+	}
+
+	canonical, err := urlnorm.Canonicalize(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return canonical
+}
+
+// resolveURL follows redirects from rawURL and returns the page's
+// rel=canonical link if it has one, or the final URL otherwise
+func (s *Service) resolveURL(ctx context.Context, rawURL string) (string, error) {
+	body, finalURL, contentType, err := s.get(ctx, rawURL)
+	if err != nil {
+		return "", err
+	}
+	if !isHTML(contentType, body) {
+		return finalURL, nil
+	}
+
+	if p, err := parsePage(body, finalURL); err == nil && p.canonical != "" {
+		return p.canonical, nil
+	}
+	return finalURL, nil
+}
@@ -0,0 +1,54 @@
+package rss
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/zyyp/backend/internal/database"
+	"github.com/zyyp/backend/pkg/simhash"
+)
+
+// fingerprint returns the simhash of an article's title and description, or
+// nil when the text is too short to fingerprint reliably
+func (a Article) fingerprint() *int64 {
+	hash, ok := simhash.Fingerprint(a.Title + " " + a.Description)
+	if !ok {
+		return nil
+	}
+	signed := int64(hash)
+	return &signed
+}
+
+// clusterArticle groups a newly stored article with the oldest near-duplicate
+// ingested in the last few days from another source. A cluster is identified
+// by the ID of its first article, which is the one listings show. Articles
+// from the same source are never grouped, as a feed's templated posts (point
+// releases, weekly digests) are often only a few bits apart.
+func clusterArticle(ctx context.Context, articleID, sourceID uuid.UUID, fingerprint *int64) error {
+	if fingerprint == nil {
+		return nil
+	}
+
+	var matchID, clusterID uuid.UUID
+	err := database.Pool.QueryRow(ctx, `
+		SELECT id, COALESCE(cluster_id, id)
+		FROM articles
+		WHERE simhash IS NOT NULL AND id <> $1
+			AND created_at > NOW() - INTERVAL '3 days'
+			AND source_id IS DISTINCT FROM $4
+			AND bit_count((simhash # $2)::bit(64)) <= $3
+		ORDER BY created_at ASC
+		LIMIT 1
+	`, articleID, *fingerprint, simhash.NearDuplicateBits, sourceID).Scan(&matchID, &clusterID)
+	if err != nil {
+		if isNoRows(err) {
+			return nil
+		}
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		UPDATE articles SET cluster_id = $1 WHERE id = ANY($2) AND cluster_id IS NULL
+	`, clusterID, []uuid.UUID{matchID, articleID})
+	return err
+}
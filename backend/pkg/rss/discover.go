@@ -0,0 +1,224 @@
+package rss
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/net/html"
+)
+
+// maxDiscoveryBody caps how much of a page or feed is read during discovery
+const maxDiscoveryBody = 5 << 20
+
+var ErrNoFeedFound = errors.New("no RSS, Atom or JSON feed found at URL")
+
+// feedLinkTypes are the <link rel="alternate"> types that advertise a feed
+var feedLinkTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+}
+
+// Discovery is a validated feed found for a URL
+type Discovery struct {
+	FeedURL    string
+	Title      string
+	FaviconURL *string
+
+	// The parsed feed, so callers need not download it again
+	feed *gofeed.Feed
+}
+
+// Discover resolves rawURL to a feed. If the URL is a feed it is used as is;
+// if it is an HTML page, the feeds it advertises are tried in order and the
+// first one that parses wins. Name and favicon are taken from the feed and
+// the page.
+func (s *Service) Discover(ctx context.Context, rawURL string) (*Discovery, error) {
+	body, finalURL, contentType, err := s.get(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if feed, err := gofeed.NewParser().Parse(bytes.NewReader(body)); err == nil {
+		return &Discovery{FeedURL: finalURL, Title: strings.TrimSpace(feed.Title), feed: feed}, nil
+	}
+
+	if !isHTML(contentType, body) {
+		return nil, ErrNoFeedFound
+	}
+
+	page, err := parsePage(body, finalURL)
+	if err != nil {
+		return nil, ErrNoFeedFound
+	}
+
+	for _, candidate := range page.feeds {
+		feedBody, feedURL, _, err := s.get(ctx, candidate)
+		if err != nil {
+			continue
+		}
+		feed, err := gofeed.NewParser().Parse(bytes.NewReader(feedBody))
+		if err != nil {
+			continue
+		}
+
+		d := &Discovery{FeedURL: feedURL, Title: strings.TrimSpace(feed.Title), feed: feed}
+		if d.Title == "" {
+			d.Title = page.title
+		}
+		if page.icon != "" {
+			d.FaviconURL = &page.icon
+		}
+		return d, nil
+	}
+
+	return nil, ErrNoFeedFound
+}
+
+// get downloads rawURL and returns the body, the URL after redirects and the
+// response content type
+func (s *Service) get(ctx context.Context, rawURL string) ([]byte, string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", "", err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, "", "", HTTPError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDiscoveryBody))
+	if err != nil {
+		return nil, "", "", err
+	}
+	return body, resp.Request.URL.String(), resp.Header.Get("Content-Type"), nil
+}
+
+func isHTML(contentType string, body []byte) bool {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+	}
+	return strings.HasPrefix(http.DetectContentType(body), "text/html")
+}
+
+// page holds what discovery needs from an HTML document
+type page struct {
+	title     string
+	icon      string
+	canonical string
+	feeds     []string
+}
+
+// parsePage collects the page title, favicon, canonical and advertised feed URLs,
+// resolving links against the document's <base> or its own URL
+func parsePage(body []byte, pageURL string) (*page, error) {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &page{}
+	seen := make(map[string]bool)
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "base":
+				if href := attr(n, "href"); href != "" {
+					if u, err := base.Parse(href); err == nil {
+						base = u
+					}
+				}
+			case "title":
+				if p.title == "" && n.FirstChild != nil {
+					p.title = strings.TrimSpace(n.FirstChild.Data)
+				}
+			case "link":
+				rels := strings.Fields(strings.ToLower(attr(n, "rel")))
+				href := resolve(base, attr(n, "href"))
+				if href == "" {
+					break
+				}
+				for _, rel := range rels {
+					switch rel {
+					case "alternate":
+						linkType := strings.ToLower(strings.TrimSpace(attr(n, "type")))
+						if feedLinkTypes[linkType] && !seen[href] {
+							seen[href] = true
+							p.feeds = append(p.feeds, href)
+						}
+					case "icon":
+						if p.icon == "" {
+							p.icon = href
+						}
+					case "canonical":
+						if p.canonical == "" {
+							p.canonical = href
+						}
+					}
+				}
+			case "body":
+				// Feed and icon links live in <head>
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	return p, nil
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+func resolve(base *url.URL, href string) string {
+	if href == "" {
+		return ""
+	}
+	u, err := base.Parse(href)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
+
+// DescribeDiscoveryError returns a message for a Discover error that is
+// safe to show to admins
+func DescribeDiscoveryError(err error) string {
+	var httpErr HTTPError
+	switch {
+	case errors.Is(err, ErrNoFeedFound):
+		return err.Error()
+	case errors.As(err, &httpErr):
+		return fmt.Sprintf("URL returned %s", httpErr.Status)
+	default:
+		return "URL could not be fetched"
+	}
+}
@@ -0,0 +1,105 @@
+package rss
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/zyyp/backend/pkg/sanitize"
+	"github.com/zyyp/backend/pkg/urlnorm"
+)
+
+// maxDescriptionLength caps, in characters, descriptions taken from content
+const maxDescriptionLength = 500
+
+// Article is a feed item normalized into the fields stored on an article
+type Article struct {
+	GUID         string
+	Title        string
+	URL          string
+	CanonicalURL string // Dedupe key, see urlnorm.Canonicalize; empty for items without a link
+	Description  string
+	Content      string
+	Author       *string
+	PublishedAt  *time.Time
+	UpdatedAt    *time.Time
+	ImageURL     *string
+	ReadingTime  int
+	Categories   []string
+}
+
+// normalizeItem extracts the article fields from a feed item
+func normalizeItem(item *gofeed.Item) Article {
+	// Content is kept as safe HTML for rendering; description is plain text
+	content := sanitize.HTML(item.Content, item.Link)
+	contentText := sanitize.Text(item.Content)
+
+	// Get description
+	description := sanitize.Text(item.Description)
+	if description == "" {
+		// Use the first 500 characters of the content
+		description = sanitize.Truncate(contentText, maxDescriptionLength)
+	}
+
+	// Get image URL
+	var imageURL *string
+	if item.Image != nil && item.Image.URL != "" {
+		imageURL = &item.Image.URL
+	}
+	// Check for media content
+	if imageURL == nil && len(item.Enclosures) > 0 {
+		for _, enclosure := range item.Enclosures {
+			if strings.HasPrefix(enclosure.Type, "image/") {
+				imageURL = &enclosure.URL
+				break
+			}
+		}
+	}
+
+	// Get author
+	var author *string
+	if len(item.Authors) > 0 {
+		author = &item.Authors[0].Name
+	} else if item.Author != nil {
+		author = &item.Author.Name
+	}
+
+	// Get published date
+	var publishedAt *time.Time
+	if item.PublishedParsed != nil {
+		publishedAt = item.PublishedParsed
+	} else if item.UpdatedParsed != nil {
+		publishedAt = item.UpdatedParsed
+	}
+
+	readingTime := readingMinutes(contentText)
+	if contentText == "" {
+		readingTime = readingMinutes(description)
+	}
+
+	canonicalURL, err := urlnorm.Canonicalize(item.Link)
+	if err != nil {
+		canonicalURL = item.Link
+	}
+
+	return Article{
+		GUID:         strings.TrimSpace(item.GUID),
+		Title:        sanitize.Text(item.Title),
+		URL:          item.Link,
+		CanonicalURL: canonicalURL,
+		Description:  description,
+		Content:      content,
+		Author:       author,
+		PublishedAt:  publishedAt,
+		UpdatedAt:    item.UpdatedParsed,
+		ImageURL:     imageURL,
+		ReadingTime:  readingTime,
+		Categories:   item.Categories,
+	}
+}
+
+// readingMinutes estimates reading time (rough: 200 words per minute)
+func readingMinutes(text string) int {
+	return int(math.Max(1, float64(len(strings.Fields(text)))/200))
+}
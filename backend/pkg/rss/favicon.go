@@ -0,0 +1,78 @@
+package rss
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/zyyp/backend/internal/database"
+)
+
+// faviconRecheck is how long to wait before looking for a missing favicon
+// again
+const faviconRecheck = 24 * time.Hour
+
+// updateFavicon looks for a favicon for a source that has none, trying the
+// feed's image, the site's <link rel="icon"> and /favicon.ico in that order
+func (s *Service) updateFavicon(ctx context.Context, src Source, feed *gofeed.Feed) {
+	if src.FaviconURL != nil {
+		return
+	}
+	if src.FaviconCheckedAt != nil && time.Since(*src.FaviconCheckedAt) < faviconRecheck {
+		return
+	}
+
+	favicon := s.findFavicon(ctx, src, feed)
+	_, err := database.Pool.Exec(ctx, `
+		UPDATE rss_sources SET favicon_url = COALESCE(favicon_url, $2), favicon_checked_at = NOW() WHERE id = $1
+	`, src.ID, favicon)
+	if err != nil {
+		log.Printf("Error updating favicon of source %s: %v", src.Name, err)
+		return
+	}
+	s.images.RegisterURLs(ctx, favicon)
+}
+
+func (s *Service) findFavicon(ctx context.Context, src Source, feed *gofeed.Feed) *string {
+	site := strings.TrimSpace(feed.Link)
+	if site == "" {
+		site = src.URL
+	}
+	base, err := url.Parse(site)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") {
+		return nil
+	}
+
+	var candidates []string
+	if feed.Image != nil {
+		candidates = append(candidates, resolve(base, feed.Image.URL))
+	}
+	if body, pageURL, contentType, err := s.get(ctx, site); err == nil && isHTML(contentType, body) {
+		if p, err := parsePage(body, pageURL); err == nil {
+			candidates = append(candidates, p.icon)
+		}
+	}
+	candidates = append(candidates, resolve(base, "/favicon.ico"))
+
+	for _, candidate := range candidates {
+		if candidate != "" && s.isImage(ctx, candidate) {
+			return &candidate
+		}
+	}
+	return nil
+}
+
+// isImage reports whether rawURL can be downloaded and holds an image the
+// image proxy can serve. Icons are often served with a generic or wrong
+// content type, so only the content is checked; SVG icons are skipped.
+func (s *Service) isImage(ctx context.Context, rawURL string) bool {
+	body, _, _, err := s.get(ctx, rawURL)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(http.DetectContentType(body), "image/")
+}
@@ -0,0 +1,95 @@
+package rss
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// feedResponse is the result of a conditional feed download
+type feedResponse struct {
+	Body         []byte
+	ETag         string
+	LastModified string
+	ContentHash  string
+	StatusCode   int
+	NotModified  bool // Server answered 304 or the body hash is unchanged
+}
+
+// HTTPError is returned when a feed responds with a non-2xx status
+type HTTPError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e HTTPError) Error() string {
+	return fmt.Sprintf("http error: %s", e.Status)
+}
+
+// fetchFeed downloads the feed for src, sending the validators stored from
+// the previous fetch so unchanged feeds can be skipped cheaply
+func (s *Service) fetchFeed(ctx context.Context, src Source) (*feedResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if src.ETag != nil && *src.ETag != "" {
+		req.Header.Set("If-None-Match", *src.ETag)
+	}
+	if src.LastModified != nil && *src.LastModified != "" {
+		req.Header.Set("If-Modified-Since", *src.LastModified)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return &feedResponse{
+			ETag:         deref(src.ETag),
+			LastModified: deref(src.LastModified),
+			ContentHash:  deref(src.ContentHash),
+			StatusCode:   resp.StatusCode,
+			NotModified:  true,
+		}, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, HTTPError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+
+	return &feedResponse{
+		Body:         body,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		ContentHash:  hash,
+		StatusCode:   resp.StatusCode,
+		NotModified:  src.ContentHash != nil && *src.ContentHash == hash,
+	}, nil
+}
+
+func isNoRows(err error) bool {
+	return errors.Is(err, pgx.ErrNoRows)
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,113 @@
+package rss
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/zyyp/backend/internal/models"
+	"github.com/zyyp/backend/pkg/sanitize"
+)
+
+// Filter rule fields
+const (
+	FilterKeyword  = RuleKeyword
+	FilterRegex    = RuleRegex
+	FilterAuthor   = "author"
+	FilterCategory = "category"
+)
+
+// itemFilter decides which feed items of a source are stored. An item must
+// match at least one include rule, if there are any, and no exclude rule.
+type itemFilter struct {
+	include []filterRule
+	exclude []filterRule
+}
+
+type filterRule struct {
+	field   string
+	value   string
+	pattern *regexp.Regexp // keyword and regex rules
+}
+
+// ValidateFilters reports the first rule that cannot be compiled
+func ValidateFilters(filters models.SourceFilters) error {
+	_, err := compileFilters(filters)
+	return err
+}
+
+func compileFilters(filters models.SourceFilters) (*itemFilter, error) {
+	f := &itemFilter{}
+	var err error
+	if f.include, err = compileFilterRules(filters.Include); err != nil {
+		return nil, fmt.Errorf("include: %w", err)
+	}
+	if f.exclude, err = compileFilterRules(filters.Exclude); err != nil {
+		return nil, fmt.Errorf("exclude: %w", err)
+	}
+	return f, nil
+}
+
+func compileFilterRules(rules []models.FilterRule) ([]filterRule, error) {
+	compiled := make([]filterRule, 0, len(rules))
+	for _, r := range rules {
+		value := strings.TrimSpace(r.Value)
+		if value == "" {
+			return nil, fmt.Errorf("%s rule has no value", r.Field)
+		}
+
+		rule := filterRule{field: r.Field, value: value}
+		switch r.Field {
+		case FilterKeyword, FilterRegex:
+			// Matched like the tag rules of the same type
+			pattern, err := CompileTagRule(r.Field, value)
+			if err != nil {
+				return nil, err
+			}
+			rule.pattern = pattern
+		case FilterAuthor, FilterCategory:
+		default:
+			return nil, fmt.Errorf("unknown rule field %q", r.Field)
+		}
+		compiled = append(compiled, rule)
+	}
+	return compiled, nil
+}
+
+// allows reports whether an item passes the source's filters
+func (f *itemFilter) allows(a Article) bool {
+	if len(f.include) == 0 && len(f.exclude) == 0 {
+		return true
+	}
+
+	text := a.Title + "\n" + a.Description + "\n" + sanitize.Text(a.Content)
+	if len(f.include) > 0 && !anyRuleMatches(f.include, a, text) {
+		return false
+	}
+	return !anyRuleMatches(f.exclude, a, text)
+}
+
+func anyRuleMatches(rules []filterRule, a Article, text string) bool {
+	for _, rule := range rules {
+		if rule.matches(a, text) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r filterRule) matches(a Article, text string) bool {
+	switch r.field {
+	case FilterAuthor:
+		return a.Author != nil && strings.EqualFold(strings.TrimSpace(*a.Author), r.value)
+	case FilterCategory:
+		for _, category := range a.Categories {
+			if strings.EqualFold(strings.TrimSpace(category), r.value) {
+				return true
+			}
+		}
+		return false
+	default:
+		return r.pattern.MatchString(text)
+	}
+}
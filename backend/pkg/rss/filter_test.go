@@ -0,0 +1,94 @@
+package rss
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zyyp/backend/internal/models"
+)
+
+func rules(specs ...string) []models.FilterRule {
+	var r []models.FilterRule
+	for _, spec := range specs {
+		field, value, _ := strings.Cut(spec, ":")
+		r = append(r, models.FilterRule{Field: field, Value: value})
+	}
+	return r
+}
+
+func TestItemFilterAllows(t *testing.T) {
+	author := " Jane Doe "
+	post := Article{
+		Title:       "Go 1.22 released",
+		Description: "Range over integers and a new HTTP router",
+		Content:     "<p>Read the <b>release notes</b> for details.</p>",
+		Author:      &author,
+		Categories:  []string{"Releases", " golang "},
+	}
+
+	tests := []struct {
+		name    string
+		filters models.SourceFilters
+		want    bool
+	}{
+		{"no rules", models.SourceFilters{}, true},
+		{"keyword in title", models.SourceFilters{Include: rules("keyword:GO")}, true},
+		{"keyword in content text", models.SourceFilters{Include: rules("keyword:release notes")}, true},
+		{"keyword matches whole words only", models.SourceFilters{Include: rules("keyword:rout")}, false},
+		{"keyword does not match markup", models.SourceFilters{Include: rules("keyword:b")}, false},
+		{"any include rule is enough", models.SourceFilters{Include: rules("keyword:rust", "author:jane doe")}, true},
+		{"no include rule matches", models.SourceFilters{Include: rules("keyword:rust", "category:security")}, false},
+		{"regex", models.SourceFilters{Include: rules(`regex:Go \d+\.\d+`)}, true},
+		{"regex is case-sensitive", models.SourceFilters{Include: rules(`regex:go \d+`)}, false},
+		{"category ignores case and spaces", models.SourceFilters{Include: rules("category:GOLANG")}, true},
+		{"author must match exactly", models.SourceFilters{Include: rules("author:Jane")}, false},
+		{"exclude rule drops the item", models.SourceFilters{Exclude: rules("category:releases")}, false},
+		{"exclude wins over include", models.SourceFilters{Include: rules("keyword:go"), Exclude: rules("keyword:router")}, false},
+		{"unmatched exclude rule keeps the item", models.SourceFilters{Include: rules("keyword:go"), Exclude: rules("keyword:sponsored")}, true},
+	}
+	for _, tt := range tests {
+		f, err := compileFilters(tt.filters)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := f.allows(post); got != tt.want {
+			t.Errorf("%s: allows = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestItemFilterWithoutAuthor(t *testing.T) {
+	f, err := compileFilters(models.SourceFilters{Exclude: rules("author:jane doe")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.allows(Article{Title: "Untitled"}) {
+		t.Error("expected an item without author to pass an author exclude rule")
+	}
+}
+
+func TestValidateFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters models.SourceFilters
+		err     string
+	}{
+		{"valid rules", models.SourceFilters{Include: rules("keyword:go", `regex:^v\d+`), Exclude: rules("author:bot", "category:ads")}, ""},
+		{"invalid include regex", models.SourceFilters{Include: rules("regex:(unclosed")}, "include: invalid regex"},
+		{"invalid exclude regex", models.SourceFilters{Exclude: rules("regex:[a-")}, "exclude: invalid regex"},
+		{"empty value", models.SourceFilters{Include: rules("keyword:  ")}, "include: keyword rule has no value"},
+		{"unknown field", models.SourceFilters{Exclude: rules("title:go")}, `exclude: unknown rule field "title"`},
+	}
+	for _, tt := range tests {
+		err := ValidateFilters(tt.filters)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("%s: error = %v, want prefix %q", tt.name, err, tt.err)
+		}
+	}
+}
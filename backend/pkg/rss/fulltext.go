@@ -0,0 +1,93 @@
+package rss
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zyyp/backend/internal/database"
+	"github.com/zyyp/backend/pkg/readability"
+	"github.com/zyyp/backend/pkg/sanitize"
+)
+
+// fullContentTimeout bounds the download of a single linked page
+const fullContentTimeout = 10 * time.Second
+
+// fullContentBudget bounds the page extraction for the articles stored by
+// one fetch of a source. Pages not reached keep the feed's content until the
+// item changes again.
+const fullContentBudget = 2 * time.Minute
+
+// fullContentJob is a stored article of a summary-only feed whose page is
+// still to be extracted
+type fullContentJob struct {
+	id      uuid.UUID
+	article Article
+	tags    *tagger
+}
+
+// fillFullContent replaces the content of articles just stored with the
+// main content of their pages and re-runs the tag rules on it. It runs after
+// the feed is stored, with its own budget, so slow pages cannot make the
+// source's fetch time out. Failures leave the stored content.
+func (s *Service) fillFullContent(ctx context.Context, jobs []fullContentJob) {
+	if len(jobs) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, fullContentBudget)
+	defer cancel()
+
+	for _, j := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		a, ok := s.withFullContent(ctx, j.article)
+		if !ok {
+			continue
+		}
+		_, err := database.Pool.Exec(ctx, `
+			UPDATE articles SET content = $2, reading_time_minutes = $3 WHERE id = $1
+		`, j.id, a.Content, a.ReadingTime)
+		if err != nil {
+			log.Printf("Error storing full content of %s: %v", a.URL, err)
+			continue
+		}
+		if _, err := applyTags(ctx, j.id, tagIDs(j.tags.match(a))); err != nil {
+			log.Printf("Error tagging article %s: %v", a.Title, err)
+		}
+	}
+}
+
+// withFullContent replaces a summary-only item's content with the main
+// content of the page it links to, when that has more words than the feed
+// provided. Failures keep the feed's content and report false.
+func (s *Service) withFullContent(ctx context.Context, a Article) (Article, bool) {
+	ctx, cancel := context.WithTimeout(ctx, fullContentTimeout)
+	defer cancel()
+
+	body, pageURL, contentType, err := s.get(ctx, a.URL)
+	if err != nil {
+		log.Printf("Error fetching full content for %s: %v", a.URL, err)
+		return a, false
+	}
+	if !isHTML(contentType, body) {
+		return a, false
+	}
+
+	extracted, err := readability.Extract(body)
+	if err != nil {
+		return a, false
+	}
+	content := sanitize.HTML(extracted, pageURL)
+	text := sanitize.Text(content)
+
+	if len(strings.Fields(text)) <= len(strings.Fields(sanitize.Text(a.Content))) {
+		return a, false
+	}
+
+	a.Content = content
+	a.ReadingTime = readingMinutes(text)
+	return a, true
+}
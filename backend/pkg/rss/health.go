@@ -0,0 +1,60 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/zyyp/backend/internal/database"
+)
+
+// maxBackoffExponent caps the exponential backoff so the multiplier stays
+// well within range no matter how long a source has been failing
+const maxBackoffExponent = 10
+
+// interrupted reports whether err comes from ctx being cancelled or running
+// out of time, as when a run is shut down or hits its deadline, rather than
+// from the source itself
+func interrupted(ctx context.Context, err error) bool {
+	return ctx.Err() != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded))
+}
+
+// recordFailure stores the error on the source, pushes its next fetch back
+// exponentially and disables it once it has failed maxFailures times in a row
+func (s *Service) recordFailure(ctx context.Context, src Source, fetchErr error) {
+	// The fetch context may be the one that just timed out
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	var statusCode *int
+	var httpErr HTTPError
+	if errors.As(fetchErr, &httpErr) {
+		statusCode = &httpErr.StatusCode
+	}
+
+	var failures int
+	var active bool
+	err := database.Pool.QueryRow(ctx, `
+		UPDATE rss_sources
+		SET consecutive_failures = consecutive_failures + 1,
+			last_error = $2,
+			last_status_code = $3,
+			last_fetched_at = NOW(),
+			next_fetch_at = NOW() + make_interval(secs => 60 * LEAST(
+				$4::float8,
+				poll_interval_minutes * POWER(2, LEAST(consecutive_failures + 1, $5))
+			)),
+			active = active AND ($6 <= 0 OR consecutive_failures + 1 < $6)
+		WHERE id = $1
+		RETURNING consecutive_failures, active
+	`, src.ID, fetchErr.Error(), statusCode, s.maxInterval.Minutes(), maxBackoffExponent, s.maxFailures).Scan(&failures, &active)
+	if err != nil {
+		log.Printf("Error recording failure for source %s: %v", src.Name, err)
+		return
+	}
+
+	if !active && s.maxFailures > 0 && failures >= s.maxFailures {
+		log.Printf("Disabled source %s after %d consecutive failures", src.Name, failures)
+	}
+}
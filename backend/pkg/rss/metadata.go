@@ -0,0 +1,255 @@
+package rss
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zyyp/backend/internal/database"
+	"github.com/zyyp/backend/pkg/sanitize"
+	"golang.org/x/net/html"
+)
+
+const (
+	// enrichBatchSize is how many articles one EnrichArticles call handles
+	enrichBatchSize = 100
+
+	// enrichMaxAge limits the backfill to recently ingested articles
+	enrichMaxAge = 7 * 24 * time.Hour
+
+	// metadataRetryAfter is how long a page that could not be fetched is
+	// left alone before it is tried again
+	metadataRetryAfter = 6 * time.Hour
+)
+
+// pageMetadata is what an article page declares about itself in its Open
+// Graph, Twitter card and article meta tags
+type pageMetadata struct {
+	ImageURL    *string
+	Description *string
+	Author      *string
+}
+
+// metaKeys lists, per field, the meta tags to read in order of preference
+var metaKeys = struct {
+	image, description, author []string
+}{
+	image:       []string{"og:image:secure_url", "og:image", "og:image:url", "twitter:image", "twitter:image:src"},
+	description: []string{"og:description", "twitter:description", "description"},
+	author:      []string{"article:author", "author", "twitter:creator"},
+}
+
+// EnrichArticles backfills the image, description and author of recent
+// articles whose feed lacked them from their page's metadata. Each page is
+// fetched once; the result is cached per URL, and requests to the same host
+// are spaced out. Pages that failed are retried after metadataRetryAfter
+// while their article is recent enough. It returns the number of articles updated.
+func (s *Service) EnrichArticles(ctx context.Context) (int, error) {
+	rows, err := database.Pool.Query(ctx, `
+		SELECT a.id, a.url
+		FROM articles a
+		WHERE a.created_at > NOW() - make_interval(secs => $1)
+			AND (a.image_url IS NULL OR COALESCE(a.description, '') = '' OR a.author IS NULL)
+			AND NOT EXISTS (
+				SELECT 1 FROM page_metadata m
+				WHERE m.url = a.url AND (m.error IS NULL OR m.fetched_at > NOW() - make_interval(secs => $3))
+			)
+		ORDER BY a.created_at DESC
+		LIMIT $2
+	`, enrichMaxAge.Seconds(), enrichBatchSize, metadataRetryAfter.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	type job struct {
+		id  uuid.UUID
+		url string
+	}
+	var jobs []job
+	for rows.Next() {
+		var j job
+		if err := rows.Scan(&j.id, &j.url); err == nil {
+			jobs = append(jobs, j)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	queue := make(chan job)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	updated := 0
+
+	for i := 0; i < s.concurrency && i < len(jobs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				meta, err := s.pageMetadata(ctx, j.url)
+				if err != nil {
+					log.Printf("Error reading metadata for %s: %v", j.url, err)
+					continue
+				}
+				ok, err := applyMetadata(ctx, j.id, meta)
+				if err != nil {
+					log.Printf("Error enriching article %s: %v", j.id, err)
+					continue
+				}
+				if ok {
+					s.images.RegisterURLs(ctx, meta.ImageURL)
+					mu.Lock()
+					updated++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for _, j := range jobs {
+		select {
+		case queue <- j:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	return updated, ctx.Err()
+}
+
+// pageMetadata returns the cached metadata of pageURL, fetching and caching
+// it first if needed. Pages that cannot be fetched are cached empty with
+// their error, and fetched again once the entry is older than
+// metadataRetryAfter.
+func (s *Service) pageMetadata(ctx context.Context, pageURL string) (*pageMetadata, error) {
+	meta := &pageMetadata{}
+	err := database.Pool.QueryRow(ctx, `
+		SELECT image_url, description, author FROM page_metadata
+		WHERE url = $1 AND (error IS NULL OR fetched_at > NOW() - make_interval(secs => $2))
+	`, pageURL, metadataRetryAfter.Seconds()).Scan(&meta.ImageURL, &meta.Description, &meta.Author)
+	if err == nil {
+		return meta, nil
+	}
+	if !isNoRows(err) {
+		return nil, err
+	}
+
+	if err := s.throttle.wait(ctx, hostOf(pageURL)); err != nil {
+		return nil, err
+	}
+
+	var fetchErr *string
+	body, finalURL, contentType, err := s.get(ctx, pageURL)
+	switch {
+	case err != nil:
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		msg := err.Error()
+		fetchErr = &msg
+	case isHTML(contentType, body):
+		meta = parseMetadata(body, finalURL)
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		INSERT INTO page_metadata (url, image_url, description, author, error)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (url) DO UPDATE
+		SET image_url = $2, description = $3, author = $4, error = $5, fetched_at = NOW()
+	`, pageURL, meta.ImageURL, meta.Description, meta.Author, fetchErr)
+	return meta, err
+}
+
+// applyMetadata fills the fields of an article that are still empty and
+// reports whether anything changed
+func applyMetadata(ctx context.Context, articleID uuid.UUID, meta *pageMetadata) (bool, error) {
+	if meta.ImageURL == nil && meta.Description == nil && meta.Author == nil {
+		return false, nil
+	}
+
+	tag, err := database.Pool.Exec(ctx, `
+		UPDATE articles
+		SET image_url = COALESCE(image_url, $2),
+			description = COALESCE(NULLIF(description, ''), $3),
+			author = COALESCE(author, $4)
+		WHERE id = $1
+			AND ((image_url IS NULL AND $2::text IS NOT NULL)
+				OR (COALESCE(description, '') = '' AND $3::text IS NOT NULL)
+				OR (author IS NULL AND $4::text IS NOT NULL))
+	`, articleID, meta.ImageURL, meta.Description, meta.Author)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// parseMetadata reads the image, description and author meta tags from the
+// head of an HTML page, resolving the image against pageURL
+func parseMetadata(body []byte, pageURL string) *pageMetadata {
+	meta := &pageMetadata{}
+
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return meta
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return meta
+	}
+
+	values := make(map[string]string)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "meta":
+				key := strings.ToLower(attr(n, "property"))
+				if key == "" {
+					key = strings.ToLower(attr(n, "name"))
+				}
+				if content := attr(n, "content"); key != "" && content != "" {
+					if _, seen := values[key]; !seen {
+						values[key] = content
+					}
+				}
+			case "body":
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	for _, key := range metaKeys.image {
+		if image := resolve(base, values[key]); image != "" {
+			meta.ImageURL = &image
+			break
+		}
+	}
+	for _, key := range metaKeys.description {
+		if description := sanitize.Truncate(sanitize.Text(values[key]), maxDescriptionLength); description != "" {
+			meta.Description = &description
+			break
+		}
+	}
+	for _, key := range metaKeys.author {
+		// article:author is often a profile URL rather than a name
+		author := strings.TrimSpace(values[key])
+		if author != "" && !strings.HasPrefix(author, "http://") && !strings.HasPrefix(author, "https://") {
+			meta.Author = &author
+			break
+		}
+	}
+	return meta
+}
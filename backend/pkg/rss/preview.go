@@ -0,0 +1,53 @@
+package rss
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/zyyp/backend/internal/models"
+)
+
+// Preview runs the FetchSource extraction against rawURL without writing
+// anything, returning the articles that would be inserted and flagging the
+// ones that would be skipped as duplicates
+func (s *Service) Preview(ctx context.Context, rawURL string) (*models.FeedPreview, error) {
+	discovery, err := s.Discover(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	articles := make([]Article, 0, len(discovery.feed.Items))
+	for _, item := range discovery.feed.Items {
+		articles = append(articles, normalizeItem(item))
+	}
+
+	stored, err := lookupArticles(ctx, uuid.Nil, articles)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := loadTagger(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &models.FeedPreview{
+		FeedURL:   discovery.FeedURL,
+		FeedTitle: discovery.Title,
+		Articles:  []models.PreviewArticle{},
+	}
+	for _, a := range articles {
+		preview.Articles = append(preview.Articles, models.PreviewArticle{
+			Title:              a.Title,
+			URL:                a.URL,
+			Description:        a.Description,
+			Author:             a.Author,
+			PublishedAt:        a.PublishedAt,
+			ImageURL:           a.ImageURL,
+			ReadingTimeMinutes: a.ReadingTime,
+			Tags:               tags.match(a),
+			Duplicate:          stored.find(a) != nil,
+		})
+	}
+
+	return preview, nil
+}
@@ -1,165 +1,428 @@
 package rss
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"log"
-	"math"
-	"strings"
+	"net/http"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/mmcdole/gofeed"
 	"github.com/zyyp/backend/internal/database"
+	"github.com/zyyp/backend/internal/models"
+	"github.com/zyyp/backend/pkg/imageproxy"
+	"github.com/zyyp/backend/pkg/safehttp"
 )
 
+var ErrSourceNotFound = errors.New("rss source not found")
+
 type Service struct {
-	parser *gofeed.Parser
+	client      *http.Client
+	concurrency int
+	timeout     time.Duration
+	hosts       *hostLimiter
+
+	defaultInterval time.Duration
+	minInterval     time.Duration
+	maxInterval     time.Duration
+	maxFailures     int
+	keepRevisions   bool
+
+	resolveCanonical bool
+
+	throttle *hostThrottle // Spaces out article page requests for metadata
+
+	images *imageproxy.Proxy
+}
+
+// Options configures how a Service fetches feeds
+type Options struct {
+	Client *http.Client // Client used for all fetches, a safehttp client by default
+
+	Concurrency  int           // Maximum number of sources fetched at once
+	PerHostLimit int           // Maximum number of concurrent fetches per host
+	Timeout      time.Duration // Timeout for fetching and storing a single source
+
+	DefaultInterval time.Duration // Polling interval for sources without one
+	MinInterval     time.Duration // Lower bound for adaptive polling intervals
+	MaxInterval     time.Duration // Upper bound for adaptive polling intervals
+
+	MaxFailures int // Consecutive failures before a source is disabled, 0 to never disable
+
+	KeepRevisions bool // Keep previous titles and descriptions when feed items change
+
+	ResolveCanonical bool // Follow redirects and rel=canonical of new article links
+
+	EnrichHostDelay time.Duration // Minimum delay between metadata requests to one host
+
+	Images *imageproxy.Proxy // Registers stored article images and favicons, nil to skip
+}
+
+// Source is an RSS source as loaded for fetching
+type Source struct {
+	ID         uuid.UUID
+	Name       string
+	URL        string
+	FaviconURL *string
+
+	// Last time a missing favicon was looked for
+	FaviconCheckedAt *time.Time
+
+	// Current polling interval in minutes
+	PollInterval int
+
+	// Validators from the previous successful fetch
+	ETag         *string
+	LastModified *string
+	ContentHash  *string
+
+	// Tags added to every article ingested from the source
+	TagIDs []uuid.UUID
+
+	// Include and exclude rules applied to items before they are stored
+	Filters models.SourceFilters
+
+	// Replace feed content with the linked page's main content
+	FetchFullContent bool
 }
 
-func NewService() *Service {
+func NewService(opts Options) *Service {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.DefaultInterval <= 0 {
+		opts.DefaultInterval = 30 * time.Minute
+	}
+	if opts.MinInterval <= 0 {
+		opts.MinInterval = 10 * time.Minute
+	}
+	if opts.MaxInterval < opts.MinInterval {
+		opts.MaxInterval = 24 * time.Hour
+	}
+	if opts.Client == nil {
+		opts.Client = safehttp.NewClient(safehttp.Options{})
+	}
 	return &Service{
-		parser: gofeed.NewParser(),
+		client:          opts.Client,
+		concurrency:     opts.Concurrency,
+		timeout:         opts.Timeout,
+		hosts:           newHostLimiter(opts.PerHostLimit),
+		defaultInterval: opts.DefaultInterval,
+		minInterval:     opts.MinInterval,
+		maxInterval:     opts.MaxInterval,
+		maxFailures:     opts.MaxFailures,
+		keepRevisions:   opts.KeepRevisions,
+
+		resolveCanonical: opts.ResolveCanonical,
+
+		throttle: newHostThrottle(opts.EnrichHostDelay),
+
+		images: opts.Images,
 	}
 }
 
-// FetchAllSources fetches articles from all active RSS sources
-func (s *Service) FetchAllSources(ctx context.Context) error {
-	rows, err := database.Pool.Query(ctx, `
-		SELECT id, name, url, favicon_url FROM rss_sources WHERE active = TRUE
-	`)
+// FetchAllSources fetches articles from all active RSS sources as part of
+// the run started with StartRun
+func (s *Service) FetchAllSources(ctx context.Context, runID uuid.UUID) error {
+	stats := &runStats{}
+
+	sources, err := loadSources(ctx, `active = TRUE`)
+	if err == nil {
+		s.fetchSources(ctx, sources, stats)
+		err = ctx.Err()
+	}
+
+	if finishErr := s.finishRun(ctx, runID, stats, err); finishErr != nil {
+		log.Printf("Error finishing fetch run %s: %v", runID, finishErr)
+	}
+	return err
+}
+
+// FetchDueSources fetches articles from active RSS sources whose next
+// scheduled fetch time has passed. A run is only recorded when at least one
+// source is due.
+func (s *Service) FetchDueSources(ctx context.Context) error {
+	sources, err := loadSources(ctx, `active = TRUE AND (next_fetch_at IS NULL OR next_fetch_at <= NOW())`)
+	if err != nil || len(sources) == 0 {
+		return err
+	}
+
+	runID, err := s.StartRun(ctx, TriggerCron)
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
 
-	type Source struct {
-		ID         uuid.UUID
-		Name       string
-		URL        string
-		FaviconURL *string
+	stats := &runStats{}
+	s.fetchSources(ctx, sources, stats)
+	err = ctx.Err()
+
+	if finishErr := s.finishRun(ctx, runID, stats, err); finishErr != nil {
+		log.Printf("Error finishing fetch run %s: %v", runID, finishErr)
+	}
+	return err
+}
+
+// FetchSourceByID fetches a single source, paused or not, and records it as
+// a manual run
+func (s *Service) FetchSourceByID(ctx context.Context, sourceID uuid.UUID) (*FetchResult, error) {
+	sources, err := loadSources(ctx, `id = $1`, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(sources) == 0 {
+		return nil, ErrSourceNotFound
+	}
+	src := sources[0]
+
+	runID, err := s.StartRun(ctx, TriggerManual)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &runStats{}
+	result, err := s.fetchWithLimits(ctx, src)
+	stats.add(src, result, err)
+
+	if finishErr := s.finishRun(ctx, runID, stats, nil); finishErr != nil {
+		log.Printf("Error finishing fetch run %s: %v", runID, finishErr)
+	}
+	return result, err
+}
+
+func loadSources(ctx context.Context, where string, args ...interface{}) ([]Source, error) {
+	rows, err := database.Pool.Query(ctx, `
+		SELECT id, name, url, favicon_url, favicon_checked_at, poll_interval_minutes, etag, last_modified, content_hash,
+			ARRAY(SELECT tag_id FROM rss_source_tags WHERE source_id = rss_sources.id), filters,
+			fetch_full_content
+		FROM rss_sources
+		WHERE `+where+`
+		ORDER BY next_fetch_at ASC NULLS FIRST
+	`, args...)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
 	var sources []Source
 	for rows.Next() {
 		var src Source
-		if err := rows.Scan(&src.ID, &src.Name, &src.URL, &src.FaviconURL); err == nil {
+		if err := rows.Scan(&src.ID, &src.Name, &src.URL, &src.FaviconURL, &src.FaviconCheckedAt, &src.PollInterval, &src.ETag, &src.LastModified, &src.ContentHash, &src.TagIDs, &src.Filters, &src.FetchFullContent); err == nil {
 			sources = append(sources, src)
 		}
 	}
+	return sources, rows.Err()
+}
+
+// fetchSources runs FetchSource for every source through a bounded worker
+// pool, holding a per-host slot for the duration of each fetch
+func (s *Service) fetchSources(ctx context.Context, sources []Source, stats *runStats) {
+	jobs := make(chan Source)
+	var wg sync.WaitGroup
+
+	workers := s.concurrency
+	if workers > len(sources) {
+		workers = len(sources)
+	}
 
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for src := range jobs {
+				result, err := s.fetchWithLimits(ctx, src)
+				if err != nil {
+					log.Printf("Error fetching source %s: %v", src.Name, err)
+				}
+				stats.add(src, result, err)
+			}
+		}()
+	}
+
+dispatch:
 	for _, src := range sources {
-		if err := s.FetchSource(ctx, src.ID, src.Name, src.URL, src.FaviconURL); err != nil {
-			log.Printf("Error fetching source %s: %v", src.Name, err)
+		select {
+		case jobs <- src:
+		case <-ctx.Done():
+			break dispatch
 		}
 	}
+	close(jobs)
+	wg.Wait()
+}
+
+func (s *Service) fetchWithLimits(ctx context.Context, src Source) (*FetchResult, error) {
+	host := hostOf(src.URL)
+	if err := s.hosts.acquire(ctx, host); err != nil {
+		return nil, err
+	}
+	defer s.hosts.release(host)
+
+	return s.FetchSource(ctx, src)
+}
+
+// FetchSource fetches articles from a single RSS source. Feeds that answer
+// 304 Not Modified or whose body is unchanged since the last fetch are not parsed.
+// Failures are recorded on the source and back off its schedule, unless the
+// fetch was cut short by ctx being cancelled or running out of time. For
+// sources with full content extraction, the article pages are downloaded
+// once the feed is stored, outside the source's fetch timeout.
+func (s *Service) FetchSource(ctx context.Context, src Source) (*FetchResult, error) {
+	fetchCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
 
-	return nil
+	result, err := s.fetchSource(fetchCtx, src)
+	if err != nil && !interrupted(ctx, err) {
+		s.recordFailure(ctx, src, err)
+	}
+	if result != nil {
+		s.fillFullContent(ctx, result.fullContent)
+	}
+	return result, err
 }
 
-// FetchSource fetches articles from a single RSS source
-func (s *Service) FetchSource(ctx context.Context, sourceID uuid.UUID, sourceName, sourceURL string, faviconURL *string) error {
-	feed, err := s.parser.ParseURL(sourceURL)
+func (s *Service) fetchSource(ctx context.Context, src Source) (*FetchResult, error) {
+	resp, err := s.fetchFeed(ctx, src)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	current := time.Duration(src.PollInterval) * time.Minute
+	result := &FetchResult{}
+
+	if resp.NotModified {
+		result.NotModified = true
+		return result, s.markFetched(ctx, src.ID, resp, s.nextInterval(current, nil, 0, time.Now()))
 	}
 
+	// gofeed parsers keep per-parse state, so each fetch gets its own
+	feed, err := gofeed.NewParser().Parse(bytes.NewReader(resp.Body))
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := compileFilters(src.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	articles := make([]Article, 0, len(feed.Items))
 	for _, item := range feed.Items {
-		// Skip if article already exists
-		var exists bool
-		err := database.Pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM articles WHERE url = $1)`, item.Link).Scan(&exists)
-		if err != nil || exists {
+		a := normalizeItem(item)
+		if !filter.allows(a) {
+			result.ItemsFiltered++
 			continue
 		}
+		articles = append(articles, a)
+	}
 
-		// Get description
-		description := item.Description
-		if description == "" && item.Content != "" {
-			// Truncate content to first 500 chars for description
-			description = item.Content
-			if len(description) > 500 {
-				description = description[:500] + "..."
-			}
-		}
+	stored, err := lookupArticles(ctx, src.ID, articles)
+	if err != nil {
+		return nil, err
+	}
 
-		// Clean HTML from description
-		description = stripHTML(description)
+	// Tagging is best effort; articles are still stored without tags
+	tags, err := loadTagger(ctx)
+	if err != nil {
+		log.Printf("Error loading tag rules: %v", err)
+	}
 
-		// Get image URL
-		var imageURL *string
-		if item.Image != nil && item.Image.URL != "" {
-			imageURL = &item.Image.URL
-		}
-		// Check for media content
-		if imageURL == nil && len(item.Enclosures) > 0 {
-			for _, enclosure := range item.Enclosures {
-				if strings.HasPrefix(enclosure.Type, "image/") {
-					imageURL = &enclosure.URL
-					break
+	result.ItemsSeen = len(feed.Items)
+	for _, a := range articles {
+		hash := a.hash()
+
+		// Update the stored article if the feed item changed, otherwise skip it
+		if existing := stored.find(a); existing != nil {
+			switch {
+			case existing.changed(a, hash):
+				// The feed's teaser must not replace page content extracted
+				// earlier; the page is extracted again afterwards
+				if err := s.updateArticle(ctx, src.ID, existing, a, hash, src.FetchFullContent); err != nil {
+					log.Printf("Error updating article %s: %v", a.Title, err)
+					continue
 				}
+				if src.FetchFullContent {
+					result.fullContent = append(result.fullContent, fullContentJob{existing.ID, a, tags})
+				}
+				s.images.RegisterURLs(ctx, a.ImageURL)
+				result.ArticlesUpdated++
+				if _, err := applyTags(ctx, existing.ID, append(tagIDs(tags.match(a)), src.TagIDs...)); err != nil {
+					log.Printf("Error tagging article %s: %v", a.Title, err)
+				}
+			case existing.SameSource && existing.ContentHash == nil:
+				if err := backfillTracking(ctx, existing, a, hash); err != nil {
+					log.Printf("Error updating article %s: %v", a.Title, err)
+				}
+				result.DuplicatesSkipped++
+			default:
+				result.DuplicatesSkipped++
 			}
+			continue
 		}
 
-		// Get author
-		var author *string
-		if len(item.Authors) > 0 {
-			author = &item.Authors[0].Name
-		} else if item.Author != nil {
-			author = &item.Author.Name
-		}
-
-		// Get published date
-		var publishedAt *time.Time
-		if item.PublishedParsed != nil {
-			publishedAt = item.PublishedParsed
-		} else if item.UpdatedParsed != nil {
-			publishedAt = item.UpdatedParsed
-		}
-
-		// Estimate reading time (rough: 200 words per minute)
-		wordCount := len(strings.Fields(item.Content))
-		if wordCount == 0 {
-			wordCount = len(strings.Fields(description))
+		// New items may redirect or declare a different canonical URL;
+		// a resolved URL that already exists is caught by the insert
+		if s.resolveCanonical {
+			a.CanonicalURL = s.CanonicalURL(ctx, a.URL)
 		}
-		readingTime := int(math.Max(1, float64(wordCount)/200))
 
 		// Insert article
-		_, err = database.Pool.Exec(ctx, `
-			INSERT INTO articles (title, url, description, content, author, published_at, source_id, source_name, image_url, reading_time_minutes)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-			ON CONFLICT (url) DO NOTHING
-		`, item.Title, item.Link, description, item.Content, author, publishedAt, sourceID, sourceName, imageURL, readingTime)
+		fingerprint := a.fingerprint()
+		var articleID uuid.UUID
+		err := database.Pool.QueryRow(ctx, `
+			INSERT INTO articles (title, url, canonical_url, description, content, author, published_at, source_id, source_name, image_url,
+				reading_time_minutes, guid, content_hash, feed_updated_at, simhash)
+			VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''), $13, $14, $15)
+			ON CONFLICT DO NOTHING
+			RETURNING id
+		`, a.Title, a.URL, a.CanonicalURL, a.Description, a.Content, a.Author, a.PublishedAt, src.ID, src.Name, a.ImageURL,
+			a.ReadingTime, a.GUID, hash, a.UpdatedAt, fingerprint).Scan(&articleID)
 
+		if isNoRows(err) {
+			result.DuplicatesSkipped++
+			continue
+		}
 		if err != nil {
-			log.Printf("Error inserting article %s: %v", item.Title, err)
+			log.Printf("Error inserting article %s: %v", a.Title, err)
+			continue
+		}
+		result.ArticlesInserted++
+		s.images.RegisterURLs(ctx, a.ImageURL)
+		if src.FetchFullContent {
+			result.fullContent = append(result.fullContent, fullContentJob{articleID, a, tags})
+		}
+
+		if err := clusterArticle(ctx, articleID, src.ID, fingerprint); err != nil {
+			log.Printf("Error clustering article %s: %v", a.Title, err)
+		}
+		if _, err := applyTags(ctx, articleID, append(tagIDs(tags.match(a)), src.TagIDs...)); err != nil {
+			log.Printf("Error tagging article %s: %v", a.Title, err)
 		}
 	}
 
-	// Update last fetched time
-	_, err = database.Pool.Exec(ctx, `
-		UPDATE rss_sources SET last_fetched_at = NOW() WHERE id = $1
-	`, sourceID)
+	s.updateFavicon(ctx, src, feed)
 
-	return err
+	interval := s.nextInterval(current, feed, feedHint(resp.Body, feed), time.Now())
+	return result, s.markFetched(ctx, src.ID, resp, interval)
 }
 
-// stripHTML removes HTML tags from a string (simple version)
-func stripHTML(s string) string {
-	var result strings.Builder
-	inTag := false
-	for _, r := range s {
-		switch r {
-		case '<':
-			inTag = true
-		case '>':
-			inTag = false
-		default:
-			if !inTag {
-				result.WriteRune(r)
-			}
-		}
-	}
-	// Collapse whitespace
-	text := result.String()
-	text = strings.Join(strings.Fields(text), " ")
-	return strings.TrimSpace(text)
+// markFetched updates the last fetched time, stores the validators to send
+// on the next fetch, clears any failure state and schedules the next fetch
+// after interval
+func (s *Service) markFetched(ctx context.Context, sourceID uuid.UUID, resp *feedResponse, interval time.Duration) error {
+	minutes := int(interval / time.Minute)
+	_, err := database.Pool.Exec(ctx, `
+		UPDATE rss_sources
+		SET last_fetched_at = NOW(), etag = NULLIF($2, ''), last_modified = NULLIF($3, ''), content_hash = $4,
+			poll_interval_minutes = $5, next_fetch_at = NOW() + make_interval(mins => $5),
+			consecutive_failures = 0, last_error = NULL, last_status_code = $6, last_success_at = NOW()
+		WHERE id = $1
+	`, sourceID, resp.ETag, resp.LastModified, resp.ContentHash, minutes, resp.StatusCode)
+
+	return err
 }
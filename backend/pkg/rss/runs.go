@@ -0,0 +1,177 @@
+package rss
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/zyyp/backend/internal/database"
+	"github.com/zyyp/backend/internal/models"
+)
+
+// Run triggers
+const (
+	TriggerCron   = "cron"
+	TriggerManual = "manual"
+)
+
+// FetchResult describes what a single source fetch ingested
+type FetchResult struct {
+	ItemsSeen         int  `json:"items_seen"`
+	ItemsFiltered     int  `json:"items_filtered"` // Dropped by the source's filters
+	ArticlesInserted  int  `json:"articles_inserted"`
+	ArticlesUpdated   int  `json:"articles_updated"`
+	DuplicatesSkipped int  `json:"duplicates_skipped"`
+	NotModified       bool `json:"not_modified"`
+
+	fullContent []fullContentJob // Stored articles whose pages are still to be extracted
+}
+
+// runStats accumulates the results of every source fetched in a run
+type runStats struct {
+	mu                sync.Mutex
+	sourcesAttempted  int
+	itemsSeen         int
+	itemsFiltered     int
+	articlesInserted  int
+	articlesUpdated   int
+	duplicatesSkipped int
+	errors            []models.FetchRunError
+}
+
+func (r *runStats) add(src Source, result *FetchResult, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sourcesAttempted++
+	if result != nil {
+		r.itemsSeen += result.ItemsSeen
+		r.itemsFiltered += result.ItemsFiltered
+		r.articlesInserted += result.ArticlesInserted
+		r.articlesUpdated += result.ArticlesUpdated
+		r.duplicatesSkipped += result.DuplicatesSkipped
+	}
+	if err != nil {
+		r.errors = append(r.errors, models.FetchRunError{SourceID: src.ID, SourceName: src.Name, Error: err.Error()})
+	}
+}
+
+// StartRun records the start of a fetch run and returns its ID
+func (s *Service) StartRun(ctx context.Context, trigger string) (uuid.UUID, error) {
+	var runID uuid.UUID
+	err := database.Pool.QueryRow(ctx, `
+		INSERT INTO fetch_runs (trigger) VALUES ($1) RETURNING id
+	`, trigger).Scan(&runID)
+	return runID, err
+}
+
+// finishRun stores the run statistics. runErr is a failure of the run as a
+// whole, as opposed to the per-source errors collected in stats.
+func (s *Service) finishRun(ctx context.Context, runID uuid.UUID, stats *runStats, runErr error) error {
+	// The run context may already be cancelled or timed out
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	errors := stats.errors
+	if errors == nil {
+		errors = []models.FetchRunError{}
+	}
+	errorsJSON, err := json.Marshal(errors)
+	if err != nil {
+		return err
+	}
+
+	status := "completed"
+	var runErrMsg *string
+	if runErr != nil {
+		status = "failed"
+		msg := runErr.Error()
+		runErrMsg = &msg
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		UPDATE fetch_runs
+		SET status = $2, finished_at = NOW(), sources_attempted = $3, items_seen = $4,
+			articles_inserted = $5, articles_updated = $6, duplicates_skipped = $7, errors = $8, error = $9,
+			items_filtered = $10
+		WHERE id = $1
+	`, runID, status, stats.sourcesAttempted, stats.itemsSeen, stats.articlesInserted,
+		stats.articlesUpdated, stats.duplicatesSkipped, errorsJSON, runErrMsg, stats.itemsFiltered)
+	return err
+}
+
+// RetagTimeout bounds a run of RetagArticles. A run still marked running
+// after it was interrupted, e.g. by a restart.
+const RetagTimeout = 30 * time.Minute
+
+var ErrRetagRunning = errors.New("tag rules are already being applied")
+
+// StartRetagRun records the start of a run of the tag rules over stored
+// articles and returns its ID. Only one run may be in progress at a time,
+// across all instances; otherwise ErrRetagRunning is returned.
+func StartRetagRun(ctx context.Context) (uuid.UUID, error) {
+	_, err := database.Pool.Exec(ctx, `
+		UPDATE tag_rule_runs
+		SET status = 'failed', finished_at = NOW(), error = 'interrupted'
+		WHERE status = 'running' AND started_at < NOW() - make_interval(secs => $1)
+	`, RetagTimeout.Seconds())
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	var runID uuid.UUID
+	err = database.Pool.QueryRow(ctx, `
+		INSERT INTO tag_rule_runs DEFAULT VALUES RETURNING id
+	`).Scan(&runID)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return uuid.Nil, ErrRetagRunning
+	}
+	return runID, err
+}
+
+// RetagArticles runs the tag rules over every stored article as part of the
+// run started with StartRetagRun, storing its totals after each batch
+func RetagArticles(ctx context.Context, runID uuid.UUID) error {
+	result, runErr := retagArticles(ctx, func(progress models.RetagResult) {
+		_, err := database.Pool.Exec(ctx, `
+			UPDATE tag_rule_runs SET articles_scanned = $2, tags_added = $3 WHERE id = $1
+		`, runID, progress.ArticlesScanned, progress.TagsAdded)
+		if err != nil {
+			log.Printf("Error recording tag rule run %s: %v", runID, err)
+		}
+	})
+	if result == nil {
+		result = &models.RetagResult{}
+	}
+
+	// The run context may already be cancelled or timed out
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	status := "completed"
+	var runErrMsg *string
+	if runErr != nil {
+		status = "failed"
+		msg := runErr.Error()
+		runErrMsg = &msg
+	}
+
+	_, err := database.Pool.Exec(ctx, `
+		UPDATE tag_rule_runs
+		SET status = $2, finished_at = NOW(), articles_scanned = $3, tags_added = $4, error = $5
+		WHERE id = $1
+	`, runID, status, result.ArticlesScanned, result.TagsAdded, runErrMsg)
+	if runErr != nil {
+		return runErr
+	}
+	return err
+}
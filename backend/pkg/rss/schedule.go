@@ -0,0 +1,153 @@
+package rss
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// recentItemsForRate is how many of the newest items are used to estimate
+// how often a feed publishes
+const recentItemsForRate = 10
+
+// nextInterval works out how long to wait before polling a source again.
+// Feeds are polled at roughly twice their publishing rate, backed off when
+// nothing changed, and never more often than the feed's own ttl or
+// sy:updatePeriod hints allow.
+func (s *Service) nextInterval(current time.Duration, feed *gofeed.Feed, hint time.Duration, now time.Time) time.Duration {
+	if current <= 0 {
+		current = s.defaultInterval
+	}
+
+	next := current
+	if feed == nil {
+		// Unchanged feed: poll a little less often
+		next = current * 5 / 4
+	} else if gap, ok := publishGap(feed.Items, now); ok {
+		next = gap / 2
+	} else {
+		// Not enough dated items to estimate a rate
+		next = current * 3 / 2
+	}
+
+	if hint > next {
+		next = hint
+	}
+	if next < s.minInterval {
+		next = s.minInterval
+	}
+	if next > s.maxInterval {
+		next = s.maxInterval
+	}
+	return next.Round(time.Minute)
+}
+
+// publishGap estimates the average time between posts from the newest
+// items. The time since the newest post counts as a gap too, so feeds that
+// have gone quiet drift towards the maximum interval.
+func publishGap(items []*gofeed.Item, now time.Time) (time.Duration, bool) {
+	var dates []time.Time
+	for _, item := range items {
+		if item.PublishedParsed != nil {
+			dates = append(dates, *item.PublishedParsed)
+		} else if item.UpdatedParsed != nil {
+			dates = append(dates, *item.UpdatedParsed)
+		}
+	}
+	if len(dates) < 2 {
+		return 0, false
+	}
+
+	sort.Slice(dates, func(i, j int) bool { return dates[i].After(dates[j]) })
+	if len(dates) > recentItemsForRate {
+		dates = dates[:recentItemsForRate]
+	}
+
+	span := dates[0].Sub(dates[len(dates)-1])
+	gap := span / time.Duration(len(dates)-1)
+	if sinceNewest := now.Sub(dates[0]); sinceNewest > gap {
+		gap = sinceNewest
+	}
+	if gap <= 0 {
+		return 0, false
+	}
+	return gap, true
+}
+
+// feedHint returns the minimum polling interval requested by the feed via
+// RSS <ttl> or the syndication module's sy:updatePeriod/sy:updateFrequency
+func feedHint(body []byte, feed *gofeed.Feed) time.Duration {
+	var hint time.Duration
+
+	if ttl := parseTTL(body); ttl > 0 {
+		hint = time.Duration(ttl) * time.Minute
+	}
+
+	if sy, ok := feed.Extensions["sy"]; ok {
+		var period time.Duration
+		if values := sy["updatePeriod"]; len(values) > 0 {
+			switch strings.ToLower(strings.TrimSpace(values[0].Value)) {
+			case "hourly":
+				period = time.Hour
+			case "daily":
+				period = 24 * time.Hour
+			case "weekly":
+				period = 7 * 24 * time.Hour
+			case "monthly":
+				period = 30 * 24 * time.Hour
+			case "yearly":
+				period = 365 * 24 * time.Hour
+			}
+		}
+		frequency := 1
+		if values := sy["updateFrequency"]; len(values) > 0 {
+			if n, err := strconv.Atoi(strings.TrimSpace(values[0].Value)); err == nil && n > 0 {
+				frequency = n
+			}
+		}
+		if period > 0 && period/time.Duration(frequency) > hint {
+			hint = period / time.Duration(frequency)
+		}
+	}
+
+	return hint
+}
+
+// parseTTL reads the channel-level <ttl> element of an RSS feed, which
+// gofeed does not expose on its universal feed type
+func parseTTL(body []byte) int {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return 0
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "item", "entry":
+			// Channel metadata comes before the items
+			return 0
+		case "ttl":
+			var value string
+			if err := decoder.DecodeElement(&value, &start); err != nil {
+				return 0
+			}
+			ttl, _ := strconv.Atoi(strings.TrimSpace(value))
+			return ttl
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package rss
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func newScheduleService() *Service {
+	return &Service{
+		defaultInterval: 40 * time.Minute,
+		minInterval:     10 * time.Minute,
+		maxInterval:     24 * time.Hour,
+	}
+}
+
+// feedPublishedEvery returns a feed of n items published every gap, the
+// newest at newest
+func feedPublishedEvery(n int, gap time.Duration, newest time.Time) *gofeed.Feed {
+	feed := &gofeed.Feed{}
+	for i := 0; i < n; i++ {
+		published := newest.Add(-time.Duration(i) * gap)
+		feed.Items = append(feed.Items, &gofeed.Item{PublishedParsed: &published})
+	}
+	return feed
+}
+
+func TestNextInterval(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	undated := &gofeed.Feed{Items: []*gofeed.Item{{}, {}, {}}}
+
+	tests := []struct {
+		name    string
+		current time.Duration
+		feed    *gofeed.Feed
+		hint    time.Duration
+		want    time.Duration
+	}{
+		{"unchanged feed backs off", 20 * time.Minute, nil, 0, 25 * time.Minute},
+		{"unset interval starts from the default", 0, nil, 0, 50 * time.Minute},
+		{"polls at twice the publishing rate", 30 * time.Minute, feedPublishedEvery(5, 2*time.Hour, now.Add(-time.Hour)), 0, time.Hour},
+		{"quiet feed counts time since the newest post", 30 * time.Minute, feedPublishedEvery(5, time.Hour, now.Add(-6*time.Hour)), 0, 3 * time.Hour},
+		{"busy feed is clamped to the minimum", 30 * time.Minute, feedPublishedEvery(10, 2*time.Minute, now), 0, 10 * time.Minute},
+		{"dead feed is clamped to the maximum", 30 * time.Minute, feedPublishedEvery(5, time.Hour, now.Add(-90*24*time.Hour)), 0, 24 * time.Hour},
+		{"undated items back off", 20 * time.Minute, undated, 0, 30 * time.Minute},
+		{"single dated item backs off", 20 * time.Minute, feedPublishedEvery(1, time.Hour, now), 0, 30 * time.Minute},
+		{"hint above the estimate wins", 30 * time.Minute, feedPublishedEvery(5, 2*time.Hour, now), 3 * time.Hour, 3 * time.Hour},
+		{"hint below the estimate is ignored", 30 * time.Minute, feedPublishedEvery(5, 2*time.Hour, now), 15 * time.Minute, time.Hour},
+		{"hint is clamped to the maximum", 30 * time.Minute, nil, 7 * 24 * time.Hour, 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := newScheduleService().nextInterval(tt.current, tt.feed, tt.hint, now); got != tt.want {
+			t.Errorf("%s: nextInterval = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFeedHint(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel string
+		want    time.Duration
+	}{
+		{"no hints", ``, 0},
+		{"ttl", `<ttl>90</ttl>`, 90 * time.Minute},
+		{"invalid ttl", `<ttl>soon</ttl>`, 0},
+		{"update period", `<sy:updatePeriod>daily</sy:updatePeriod>`, 24 * time.Hour},
+		{"update period and frequency", `<sy:updatePeriod>daily</sy:updatePeriod><sy:updateFrequency>4</sy:updateFrequency>`, 6 * time.Hour},
+		{"invalid frequency", `<sy:updatePeriod>hourly</sy:updatePeriod><sy:updateFrequency>0</sy:updateFrequency>`, time.Hour},
+		{"unknown period", `<sy:updatePeriod>fortnightly</sy:updatePeriod>`, 0},
+		{"larger hint wins", `<ttl>30</ttl><sy:updatePeriod>hourly</sy:updatePeriod>`, time.Hour},
+		{"ttl after the first item is ignored", `<item><title>Post</title><ttl>60</ttl></item>`, 0},
+	}
+	for _, tt := range tests {
+		body := []byte(`<?xml version="1.0"?>
+<rss version="2.0" xmlns:sy="http://purl.org/rss/1.0/modules/syndication/">
+<channel><title>Feed</title>` + tt.channel + `</channel>
+</rss>`)
+		feed, err := gofeed.NewParser().ParseString(string(body))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := feedHint(body, feed); got != tt.want {
+			t.Errorf("%s: feedHint = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
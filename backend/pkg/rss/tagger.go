@@ -0,0 +1,243 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/zyyp/backend/internal/database"
+	"github.com/zyyp/backend/internal/models"
+	"github.com/zyyp/backend/pkg/sanitize"
+)
+
+const (
+	RuleKeyword = "keyword"
+	RuleRegex   = "regex"
+)
+
+// retagBatchSize is how many stored articles RetagArticles loads at a time
+const retagBatchSize = 500
+
+var ErrInvalidRuleType = errors.New("match_type must be keyword or regex")
+
+// tagger assigns tags to articles from their feed categories and from the
+// keyword and regex rules in tag_rules
+type tagger struct {
+	tags  []models.Tag
+	rules []tagRule
+}
+
+type tagRule struct {
+	tag     models.Tag
+	pattern *regexp.Regexp
+}
+
+// CompileTagRule validates a rule and returns the expression it matches
+// with. Keywords match case-insensitively as whole words; regexes are used
+// as written.
+func CompileTagRule(matchType, pattern string) (*regexp.Regexp, error) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return nil, errors.New("pattern is required")
+	}
+
+	switch matchType {
+	case RuleKeyword:
+		return regexp.Compile(`(?i)(?:^|[^\pL\pN])` + regexp.QuoteMeta(pattern) + `(?:$|[^\pL\pN])`)
+	case RuleRegex:
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+		return re, nil
+	default:
+		return nil, ErrInvalidRuleType
+	}
+}
+
+// loadTags returns every tag
+func loadTags(ctx context.Context) ([]models.Tag, error) {
+	rows, err := database.Pool.Query(ctx, `SELECT id, name, slug, color, created_at FROM tags`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []models.Tag
+	for rows.Next() {
+		var t models.Tag
+		if err := rows.Scan(&t.ID, &t.Name, &t.Slug, &t.Color, &t.CreatedAt); err == nil {
+			tags = append(tags, t)
+		}
+	}
+	return tags, rows.Err()
+}
+
+// loadTagger loads all tags and their rules. Rules that no longer compile
+// are logged and skipped.
+func loadTagger(ctx context.Context) (*tagger, error) {
+	tags, err := loadTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]models.Tag, len(tags))
+	for _, t := range tags {
+		byID[t.ID] = t
+	}
+
+	rows, err := database.Pool.Query(ctx, `SELECT id, tag_id, match_type, pattern FROM tag_rules`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	t := &tagger{tags: tags}
+	for rows.Next() {
+		var ruleID, tagID uuid.UUID
+		var matchType, pattern string
+		if err := rows.Scan(&ruleID, &tagID, &matchType, &pattern); err != nil {
+			continue
+		}
+		re, err := CompileTagRule(matchType, pattern)
+		if err != nil {
+			log.Printf("Skipping tag rule %s: %v", ruleID, err)
+			continue
+		}
+		if tag, ok := byID[tagID]; ok {
+			t.rules = append(t.rules, tagRule{tag: tag, pattern: re})
+		}
+	}
+	return t, rows.Err()
+}
+
+// match returns the tags for an article: those named by its feed
+// categories, then those with a rule matching its title or body
+func (t *tagger) match(a Article) []models.Tag {
+	if t == nil {
+		return []models.Tag{}
+	}
+
+	matched := matchCategoryTags(t.tags, a.Categories)
+	seen := make(map[uuid.UUID]bool, len(matched))
+	for _, tag := range matched {
+		seen[tag.ID] = true
+	}
+	if len(t.rules) == 0 {
+		return matched
+	}
+
+	text := a.Title + "\n" + a.Description + "\n" + sanitize.Text(a.Content)
+	for _, rule := range t.rules {
+		if !seen[rule.tag.ID] && rule.pattern.MatchString(text) {
+			seen[rule.tag.ID] = true
+			matched = append(matched, rule.tag)
+		}
+	}
+	return matched
+}
+
+// matchCategoryTags returns the tags whose name or slug equals one of the
+// feed item's categories, ignoring case
+func matchCategoryTags(tags []models.Tag, categories []string) []models.Tag {
+	matched := []models.Tag{}
+	for _, t := range tags {
+		for _, category := range categories {
+			category = strings.TrimSpace(category)
+			if strings.EqualFold(category, t.Name) || strings.EqualFold(category, t.Slug) {
+				matched = append(matched, t)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+func tagIDs(tags []models.Tag) []uuid.UUID {
+	ids := make([]uuid.UUID, len(tags))
+	for i, t := range tags {
+		ids[i] = t.ID
+	}
+	return ids
+}
+
+// applyTags adds tags to an article, keeping the ones it already has, and
+// returns how many were new
+func applyTags(ctx context.Context, articleID uuid.UUID, tagIDs []uuid.UUID) (int64, error) {
+	if len(tagIDs) == 0 {
+		return 0, nil
+	}
+
+	tag, err := database.Pool.Exec(ctx, `
+		INSERT INTO article_tags (article_id, tag_id)
+		SELECT DISTINCT $1::uuid, unnest($2::uuid[])
+		ON CONFLICT DO NOTHING
+	`, articleID, tagIDs)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// retagArticles runs the tag rules over every stored article. Feed
+// categories are not stored, so only keyword and regex rules apply.
+// progress is called with the running totals after each batch.
+func retagArticles(ctx context.Context, progress func(models.RetagResult)) (*models.RetagResult, error) {
+	t, err := loadTagger(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.RetagResult{}
+	if len(t.rules) == 0 {
+		return result, nil
+	}
+
+	var lastID uuid.UUID
+	for {
+		rows, err := database.Pool.Query(ctx, `
+			SELECT id, title, COALESCE(description, ''), COALESCE(content, '')
+			FROM articles
+			WHERE id > $1
+			ORDER BY id
+			LIMIT $2
+		`, lastID, retagBatchSize)
+		if err != nil {
+			return result, err
+		}
+
+		type batchItem struct {
+			id      uuid.UUID
+			article Article
+		}
+		var batch []batchItem
+		for rows.Next() {
+			var item batchItem
+			if err := rows.Scan(&item.id, &item.article.Title, &item.article.Description, &item.article.Content); err != nil {
+				continue
+			}
+			batch = append(batch, item)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return result, err
+		}
+		if len(batch) == 0 {
+			return result, nil
+		}
+
+		for _, item := range batch {
+			added, err := applyTags(ctx, item.id, tagIDs(t.match(item.article)))
+			if err != nil {
+				return result, err
+			}
+			result.ArticlesScanned++
+			result.TagsAdded += int(added)
+		}
+		lastID = batch[len(batch)-1].id
+		progress(*result)
+	}
+}
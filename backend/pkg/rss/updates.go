@@ -0,0 +1,164 @@
+package rss
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zyyp/backend/internal/database"
+)
+
+// storedArticle is the part of an existing article needed to detect changes
+type storedArticle struct {
+	ID            uuid.UUID
+	SameSource    bool // Ingested from the source being fetched
+	ContentHash   *string
+	FeedUpdatedAt *time.Time
+	Simhash       *int64
+}
+
+// storedArticles indexes existing articles by source GUID and by original
+// and canonical URL
+type storedArticles struct {
+	byGUID map[string]*storedArticle
+	byURL  map[string]*storedArticle
+}
+
+// find returns the stored article for a, matching on GUID before URL
+func (s *storedArticles) find(a Article) *storedArticle {
+	if a.GUID != "" {
+		if stored, ok := s.byGUID[a.GUID]; ok {
+			return stored
+		}
+	}
+	for _, url := range []string{a.CanonicalURL, a.URL} {
+		if stored, ok := s.byURL[url]; ok && url != "" {
+			return stored
+		}
+	}
+	return nil
+}
+
+// lookupArticles loads the already stored articles for a feed's items in a
+// single query. GUIDs are only matched within sourceID, since they are only
+// unique per feed; pass uuid.Nil to match on URL alone.
+func lookupArticles(ctx context.Context, sourceID uuid.UUID, articles []Article) (*storedArticles, error) {
+	urls := make([]string, 0, 2*len(articles))
+	guids := make([]string, 0, len(articles))
+	for _, a := range articles {
+		urls = append(urls, a.URL, a.CanonicalURL)
+		if a.GUID != "" {
+			guids = append(guids, a.GUID)
+		}
+	}
+
+	rows, err := database.Pool.Query(ctx, `
+		SELECT id, url, canonical_url, guid, source_id = $3 AS same_source, content_hash, feed_updated_at, simhash
+		FROM articles
+		WHERE canonical_url = ANY($1) OR url = ANY($1) OR (source_id = $3 AND guid = ANY($2))
+	`, urls, guids, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := &storedArticles{
+		byGUID: make(map[string]*storedArticle),
+		byURL:  make(map[string]*storedArticle),
+	}
+	for rows.Next() {
+		var a storedArticle
+		var url string
+		var canonicalURL, guid *string
+		var sameSource *bool
+		if err := rows.Scan(&a.ID, &url, &canonicalURL, &guid, &sameSource, &a.ContentHash, &a.FeedUpdatedAt, &a.Simhash); err != nil {
+			continue
+		}
+		a.SameSource = sameSource != nil && *sameSource
+		stored.byURL[url] = &a
+		if canonicalURL != nil {
+			stored.byURL[*canonicalURL] = &a
+		}
+		if guid != nil && a.SameSource {
+			stored.byGUID[*guid] = &a
+		}
+	}
+	return stored, rows.Err()
+}
+
+// hash fingerprints the fields of an article that feed edits can change
+func (a Article) hash() string {
+	h := sha256.New()
+	for _, field := range []string{a.Title, a.Description, a.Content, deref(a.ImageURL)} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// changed reports whether a is newer than or differs from the stored
+// version. Articles from other sources are never changed by this feed.
+func (stored *storedArticle) changed(a Article, hash string) bool {
+	if !stored.SameSource {
+		return false
+	}
+	if a.UpdatedAt != nil && stored.FeedUpdatedAt != nil && a.UpdatedAt.After(*stored.FeedUpdatedAt) {
+		return true
+	}
+	return stored.ContentHash != nil && *stored.ContentHash != hash
+}
+
+// updateArticle overwrites a stored article with the feed's current version,
+// optionally keeping the previous title and description as a revision. An
+// article whose fingerprint changed leaves its near-duplicate cluster and is
+// clustered again, unless other articles are grouped under it. With
+// keepLonger, stored content longer than the new content is kept.
+func (s *Service) updateArticle(ctx context.Context, sourceID uuid.UUID, stored *storedArticle, a Article, hash string, keepLonger bool) error {
+	if s.keepRevisions {
+		_, err := database.Pool.Exec(ctx, `
+			INSERT INTO article_revisions (article_id, title, description)
+			SELECT id, title, description FROM articles
+			WHERE id = $1 AND (title IS DISTINCT FROM $2 OR description IS DISTINCT FROM $3)
+		`, stored.ID, a.Title, a.Description)
+		if err != nil {
+			return err
+		}
+	}
+
+	fingerprint := a.fingerprint()
+	_, err := database.Pool.Exec(ctx, `
+		UPDATE articles
+		SET title = $2, description = $3,
+			content = CASE WHEN $12 AND length(content) > length($4::text) THEN content ELSE $4 END,
+			reading_time_minutes = CASE WHEN $12 AND length(content) > length($4::text) THEN reading_time_minutes ELSE $7 END,
+			author = COALESCE($5, author), image_url = COALESCE($6, image_url),
+			guid = COALESCE(NULLIF($8, ''), guid), content_hash = $9, feed_updated_at = $10,
+			simhash = $11, updated_at = NOW(),
+			cluster_id = CASE WHEN simhash IS DISTINCT FROM $11 AND cluster_id <> id THEN NULL ELSE cluster_id END
+		WHERE id = $1
+	`, stored.ID, a.Title, a.Description, a.Content, a.Author, a.ImageURL, a.ReadingTime, a.GUID, hash, a.UpdatedAt, fingerprint, keepLonger)
+	if err != nil || sameFingerprint(stored.Simhash, fingerprint) {
+		return err
+	}
+	return clusterArticle(ctx, stored.ID, sourceID, fingerprint)
+}
+
+func sameFingerprint(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// backfillTracking stores the GUID and hash on an article ingested before
+// they were tracked, without treating it as an update
+func backfillTracking(ctx context.Context, stored *storedArticle, a Article, hash string) error {
+	_, err := database.Pool.Exec(ctx, `
+		UPDATE articles
+		SET guid = COALESCE(guid, NULLIF($2, '')), content_hash = $3, feed_updated_at = COALESCE(feed_updated_at, $4)
+		WHERE id = $1
+	`, stored.ID, a.GUID, hash, a.UpdatedAt)
+	return err
+}
@@ -0,0 +1,249 @@
+package safehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// DefaultUserAgent identifies the server on outbound requests
+const DefaultUserAgent = "Zyyp/1.0 (+https://github.com/iAmNsengi/zyyp)"
+
+var (
+	ErrBlockedAddress    = errors.New("safehttp: destination address is not allowed")
+	ErrUnsupportedScheme = errors.New("safehttp: only http and https URLs can be fetched")
+	ErrTooManyRedirects  = errors.New("safehttp: too many redirects")
+	ErrBodyTooLarge      = errors.New("safehttp: response body is too large")
+)
+
+// blockedRanges are special-purpose ranges not covered by the netip.Addr
+// predicates checked in isPublic
+var blockedRanges = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "This" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // Documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // Benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // Documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // Documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // Reserved, including broadcast
+	netip.MustParsePrefix("::/96"),           // IPv4-compatible, deprecated
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, may map to private IPv4
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // Local-use NAT64
+	netip.MustParsePrefix("2001::/32"),       // Teredo, embeds an IPv4 address
+	netip.MustParsePrefix("2002::/16"),       // 6to4, embeds an IPv4 address
+	netip.MustParsePrefix("2001:db8::/32"),   // Documentation
+}
+
+type Options struct {
+	Timeout      time.Duration // Overall timeout of a request, including reading the body
+	MaxRedirects int           // Redirects followed before giving up
+	MaxBodyBytes int64         // Largest response body accepted
+	UserAgent    string        // Sent when a request sets none
+
+	// Private, loopback or link-local networks that may still be fetched,
+	// e.g. an internal feed server
+	AllowedNetworks []netip.Prefix
+}
+
+// NewClient returns an HTTP client for fetching user-supplied URLs. Host
+// names are resolved before connecting and the connection is refused when
+// any of their addresses is private, loopback, link-local or otherwise not
+// publicly routable, unless allow-listed. The connection is made to the
+// checked address, so a second DNS answer cannot point it elsewhere.
+// Environment proxies are ignored for the same reason.
+func NewClient(opts Options) *http.Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.MaxRedirects <= 0 {
+		opts.MaxRedirects = 5
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = 20 << 20
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultUserAgent
+	}
+
+	d := &dialer{
+		dialer:   &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second},
+		resolver: net.DefaultResolver,
+		allowed:  opts.AllowedNetworks,
+	}
+	base := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           d.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   4,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 15 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &transport{
+			base:      base,
+			userAgent: opts.UserAgent,
+			maxBody:   opts.MaxBodyBytes,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > opts.MaxRedirects {
+				return ErrTooManyRedirects
+			}
+			return nil
+		},
+	}
+}
+
+// ParseNetworks parses a comma-separated list of CIDR prefixes or single
+// addresses
+func ParseNetworks(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// isPublic reports whether addr is a publicly routable unicast address
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range blockedRanges {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// dialer resolves the host before connecting and only dials checked
+// addresses
+type dialer struct {
+	dialer   *net.Dialer
+	resolver *net.Resolver
+	allowed  []netip.Prefix
+}
+
+func (d *dialer) permitted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range d.allowed {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return isPublic(addr)
+}
+
+func (d *dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := d.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("safehttp: no addresses found for %s", host)
+	}
+	// A host with any disallowed address is refused outright, so round-robin
+	// DNS cannot be used to sneak in an internal address
+	for _, addr := range addrs {
+		if !d.permitted(addr) {
+			return nil, fmt.Errorf("%w: %s resolves to %s", ErrBlockedAddress, host, addr.Unmap())
+		}
+	}
+
+	var lastErr error
+	for _, addr := range addrs {
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(addr.Unmap().String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// transport checks the scheme of every request, including redirects, sets
+// the User-Agent and caps response bodies
+type transport struct {
+	base      http.RoundTripper
+	userAgent string
+	maxBody   int64
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, ErrUnsupportedScheme
+	}
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ContentLength > t.maxBody {
+		resp.Body.Close()
+		return nil, ErrBodyTooLarge
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: t.maxBody}
+	return resp, nil
+}
+
+// limitedBody fails with ErrBodyTooLarge once more than the allowed bytes
+// are read, rather than silently truncating the body
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		var probe [1]byte
+		n, err := b.ReadCloser.Read(probe[:])
+		if n > 0 {
+			return 0, ErrBodyTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,48 @@
+package sanitize
+
+import "testing"
+
+const page = "https://example.com/blog/post"
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		fragment string
+		want     string
+	}{
+		{"keeps formatting", `<p>Hello <strong>world</strong></p>`, `<p>Hello <strong>world</strong></p>`},
+		{"drops script", `<p>Hi</p><script>alert(1)</script>`, `<p>Hi</p>`},
+		{"drops style", `<style>p { color: red }</style><p>Hi</p>`, `<p>Hi</p>`},
+		{"drops nested script", `<div><span>a<script>alert(1)</script>b</span></div>`, `<div><span>ab</span></div>`},
+		{"unwraps unknown elements", `<section><article>Text</article></section>`, `Text`},
+		{"strips event handlers", `<p onclick="alert(1)" onmouseover="x()">Hi</p>`, `<p>Hi</p>`},
+		{"strips event handlers on images", `<img src="/a.png" onerror="alert(1)">`, `<img src="https://example.com/a.png"/>`},
+		{"strips other attributes", `<p class="lead" style="color:red" id="x">Hi</p>`, `<p>Hi</p>`},
+		{"rejects javascript href", `<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"rejects javascript href with spaces and case", `<a href="  JavaScript:alert(1)">x</a>`, `<a>x</a>`},
+		{"rejects data href", `<a href="data:text/html;base64,PHNjcmlwdD4=">x</a>`, `<a>x</a>`},
+		{"rejects data src", `<p><img src="data:image/png;base64,iVBORw0KGgo=" alt="pixel"></p>`, `<p></p>`},
+		{"rejects javascript src", `<img src="javascript:alert(1)">`, ``},
+		{"keeps mailto", `<a href="mailto:me@example.com">mail</a>`, `<a href="mailto:me@example.com" rel="nofollow noopener noreferrer">mail</a>`},
+		{"resolves relative href", `<a href="../about">About</a>`, `<a href="https://example.com/about" rel="nofollow noopener noreferrer">About</a>`},
+		{"resolves root-relative src", `<img src="/img/cover.jpg" alt="Cover">`, `<img src="https://example.com/img/cover.jpg" alt="Cover"/>`},
+		{"resolves scheme-relative src", `<img src="//cdn.example.net/a.png">`, `<img src="https://cdn.example.net/a.png"/>`},
+		{"keeps absolute href", `<a href="http://other.org/x" title="X">x</a>`, `<a href="http://other.org/x" title="X" rel="nofollow noopener noreferrer">x</a>`},
+		{"drops forms", `<form action="/login"><input name="user"><button>Go</button></form><p>After</p>`, `<p>After</p>`},
+		{"escapes text", `<p>1 &lt; 2 &amp; 3</p>`, `<p>1 &lt; 2 &amp; 3</p>`},
+	}
+	for _, tt := range tests {
+		if got := HTML(tt.fragment, page); got != tt.want {
+			t.Errorf("%s: HTML(%q) = %q, want %q", tt.name, tt.fragment, got, tt.want)
+		}
+	}
+}
+
+// Relative URLs that cannot be resolved are dropped
+func TestHTMLWithoutBaseURL(t *testing.T) {
+	got := HTML(`<a href="/about">About</a><img src="a.png">`, "")
+	want := `<a>About</a>`
+	if got != want {
+		t.Errorf("HTML = %q, want %q", got, want)
+	}
+}
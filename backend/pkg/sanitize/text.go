@@ -0,0 +1,106 @@
+package sanitize
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Ellipsis is appended to truncated text
+const Ellipsis = "..."
+
+// blockTags start a new line in extracted text
+var blockTags = map[atom.Atom]bool{
+	atom.P: true, atom.Br: true, atom.Hr: true, atom.Div: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Blockquote: true, atom.Pre: true, atom.Ul: true, atom.Ol: true, atom.Li: true,
+	atom.Dl: true, atom.Dt: true, atom.Dd: true, atom.Table: true, atom.Tr: true,
+	atom.Figure: true, atom.Figcaption: true, atom.Article: true, atom.Section: true,
+	atom.Header: true, atom.Footer: true, atom.Main: true, atom.Aside: true,
+}
+
+// Text returns the readable text of an HTML fragment with entities decoded,
+// script and style contents removed, one line per block element and runs of
+// whitespace collapsed. Plain text input is returned cleaned up the same way.
+func Text(fragment string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return strings.Join(strings.Fields(fragment), " ")
+	}
+
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			if droppedTags[n.DataAtom] {
+				return
+			}
+		}
+
+		block := n.Type == html.ElementNode && blockTags[n.DataAtom]
+		if block {
+			b.WriteByte('\n')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if block {
+			b.WriteByte('\n')
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Truncate shortens text to at most max runes, including the ellipsis it
+// appends. It cuts at the last word boundary when one is reasonably close,
+// and never splits a rune.
+func Truncate(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+
+	limit := max - utf8.RuneCountInString(Ellipsis)
+	if limit <= 0 {
+		return ""
+	}
+	all := []rune(text)
+	runes := all[:limit]
+
+	// Back up to the last space, unless the cut already falls between words
+	// or backing up would drop more than half the text
+	cut := limit
+	if !unicode.IsSpace(all[limit]) {
+		for i := limit - 1; i > limit/2; i-- {
+			if unicode.IsSpace(runes[i]) {
+				cut = i
+				break
+			}
+		}
+	}
+
+	truncated := strings.TrimRightFunc(string(runes[:cut]), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	})
+	return truncated + Ellipsis
+}
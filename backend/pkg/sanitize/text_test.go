@@ -0,0 +1,74 @@
+package sanitize
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name     string
+		fragment string
+		want     string
+	}{
+		{"plain text", "  Hello   world \n", "Hello world"},
+		{"decodes entities", "Caf&eacute; &amp; cr&egrave;me &#8211; &lt;tag&gt;", "Café & crème – <tag>"},
+		{"drops script and style", "<style>p{}</style>Hi<script>alert(1)</script> there", "Hi there"},
+		{"one line per block", "<h1>Title</h1><p>First <b>para</b></p><ul><li>one</li><li>two</li></ul>", "Title\nFirst para\none\ntwo"},
+		{"inline elements join", "Go<em>lang</em> <code>fmt</code>", "Golang fmt"},
+		{"multibyte text", "<p>日本語の  記事</p><p>Ελληνικά κείμενο 🚀</p>", "日本語の 記事\nΕλληνικά κείμενο 🚀"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		if got := Text(tt.fragment); got != tt.want {
+			t.Errorf("%s: Text(%q) = %q, want %q", tt.name, tt.fragment, got, tt.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		max  int
+		want string
+	}{
+		{"short text is kept", "Hello world", 20, "Hello world"},
+		{"exact length is kept", "Hello world", 11, "Hello world"},
+		{"cuts at a word boundary", "Hello big wonderful world", 15, "Hello big..."},
+		{"cuts mid-word rather than dropping half", "Hello wonderful world", 15, "Hello wonder..."},
+		{"cut between words", "Hello world again", 14, "Hello world..."},
+		{"trims punctuation before the ellipsis", "Hello big, wonderful world", 15, "Hello big..."},
+		{"long word is cut mid-word", "Supercalifragilistic", 10, "Superca..."},
+		{"multibyte runes are counted, not bytes", "日本語の記事です", 8, "日本語の記事です"},
+		{"runes are never split", "日本語の記事です", 6, "日本語..."},
+		{"multibyte words", "Ελληνικά κείμενο εδώ", 12, "Ελληνικά..."},
+		{"max shorter than the ellipsis", "Hello world", 2, ""},
+	}
+	for _, tt := range tests {
+		got := Truncate(tt.text, tt.max)
+		if got != tt.want {
+			t.Errorf("%s: Truncate(%q, %d) = %q, want %q", tt.name, tt.text, tt.max, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("%s: Truncate returned invalid UTF-8 %q", tt.name, got)
+		}
+	}
+}
+
+func TestTruncateDescriptionLength(t *testing.T) {
+	const max = 500
+	text := strings.Repeat("数据库 ", 200) // 800 runes, 2200 bytes
+
+	got := Truncate(text, max)
+	if n := utf8.RuneCountInString(got); n > max {
+		t.Errorf("got %d runes, want at most %d", n, max)
+	}
+	if !strings.HasSuffix(got, Ellipsis) || !utf8.ValidString(got) {
+		t.Errorf("got %q", got)
+	}
+	if strings.HasSuffix(strings.TrimSuffix(got, Ellipsis), " ") || !strings.HasSuffix(strings.TrimSuffix(got, Ellipsis), "数据库") {
+		t.Errorf("expected a cut after a whole word, got %q", got[len(got)-20:])
+	}
+}
@@ -0,0 +1,61 @@
+package simhash
+
+import (
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+// MinTokens is the fewest words a text needs for its fingerprint to be
+// meaningful; shorter texts collide too easily
+const MinTokens = 8
+
+// NearDuplicateBits is the largest Hamming distance at which two
+// fingerprints are treated as the same text lightly edited
+const NearDuplicateBits = 3
+
+// Fingerprint computes a 64-bit simhash of text over overlapping word pairs.
+// Texts that share most of their wording get fingerprints only a few bits
+// apart, so near-duplicates can be found by Hamming distance.
+// The second result is false when text has fewer than MinTokens words.
+func Fingerprint(text string) (uint64, bool) {
+	tokens := tokenize(text)
+	if len(tokens) < MinTokens {
+		return 0, false
+	}
+
+	var weights [64]int
+	for i := range tokens {
+		shingle := tokens[i]
+		if i+1 < len(tokens) {
+			shingle += " " + tokens[i+1]
+		}
+
+		h := fnv.New64a()
+		h.Write([]byte(shingle))
+		sum := h.Sum64()
+
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<uint(bit)) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << uint(bit)
+		}
+	}
+	return fingerprint, true
+}
+
+// tokenize lowercases text and splits it into words, dropping punctuation
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
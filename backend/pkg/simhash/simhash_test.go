@@ -0,0 +1,75 @@
+package simhash
+
+import (
+	"math/bits"
+	"strings"
+	"testing"
+)
+
+const release = "Go 1.22.1 is released. The Go team is happy to announce the release of Go 1.22.1, " +
+	"a minor point release. It includes two security fixes to the net/http and crypto/x509 packages, " +
+	"as well as bug fixes to the compiler, the go command, the runtime, and the net/http, os and syscall " +
+	"packages. See the release notes and the milestone on the issue tracker for details. You can " +
+	"download binary and source distributions from the download page."
+
+func distance(t *testing.T, a, b string) int {
+	t.Helper()
+	fa, ok := Fingerprint(a)
+	if !ok {
+		t.Fatalf("no fingerprint for %q", a)
+	}
+	fb, ok := Fingerprint(b)
+	if !ok {
+		t.Fatalf("no fingerprint for %q", b)
+	}
+	return bits.OnesCount64(fa ^ fb)
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		duplicate bool
+	}{
+		{"identical", release, true},
+		{"case differs", strings.ToUpper(release), true},
+		{"punctuation differs", strings.ReplaceAll(release, ",", " ;"), true},
+		{"one word edited", strings.ReplaceAll(release, "download page", "downloads page"), true},
+		{"different story", "Rust 1.80 stabilizes LazyCell and LazyLock, adds exclusive ranges in patterns, " +
+			"and checks cfg names and values at compile time. The Rust team is happy to announce a new " +
+			"version of Rust, a language empowering everyone to build reliable and efficient software.", false},
+	}
+	for _, tt := range tests {
+		d := distance(t, release, tt.text)
+		if got := d <= NearDuplicateBits; got != tt.duplicate {
+			t.Errorf("%s: distance %d, near duplicate = %v, want %v", tt.name, d, got, tt.duplicate)
+		}
+	}
+}
+
+func TestFingerprintShortText(t *testing.T) {
+	tests := []struct {
+		text string
+		ok   bool
+	}{
+		{"", false},
+		{"Go 1.22 is out", false},
+		{"one two three four five six seven", false},
+		// Punctuation does not count as words
+		{"one, two - three: four! five? six; seven ... !!!", false},
+		{"one two three four five six seven eight", true},
+	}
+	for _, tt := range tests {
+		if _, ok := Fingerprint(tt.text); ok != tt.ok {
+			t.Errorf("Fingerprint(%q) ok = %v, want %v", tt.text, ok, tt.ok)
+		}
+	}
+}
+
+func TestFingerprintIsStable(t *testing.T) {
+	a, _ := Fingerprint(release)
+	b, _ := Fingerprint(release)
+	if a != b {
+		t.Errorf("fingerprints differ: %x and %x", a, b)
+	}
+}
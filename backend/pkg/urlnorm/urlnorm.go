@@ -0,0 +1,114 @@
+package urlnorm
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+var ErrUnsupportedURL = errors.New("urlnorm: only absolute http and https URLs are supported")
+
+// trackingParams are query parameters that only identify where a click came
+// from and never change the page being linked to
+var trackingParams = map[string]bool{
+	"fbclid":   true,
+	"gclid":    true,
+	"dclid":    true,
+	"msclkid":  true,
+	"yclid":    true,
+	"igshid":   true,
+	"mc_cid":   true,
+	"mc_eid":   true,
+	"mkt_tok":  true,
+	"_hsenc":   true,
+	"_hsmi":    true,
+	"ref_src":  true,
+	"ref_url":  true,
+	"cmpid":    true,
+	"ncid":     true,
+	"sr_share": true,
+	"spm":      true,
+}
+
+// trackingPrefixes are query parameter prefixes used by tracking schemes
+var trackingPrefixes = []string{"utm_", "pk_", "mtm_"}
+
+// Canonicalize normalizes a URL so that links to the same page compare equal.
+// It lowercases the host, drops default ports, fragments and tracking
+// parameters, sorts the remaining query parameters and removes trailing
+// slashes from non-root paths. http links are upgraded to https, so the two
+// copies of a post served on both schemes get the same key; the result is a
+// dedupe key, not a URL to fetch.
+func Canonicalize(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if (scheme != "http" && scheme != "https") || u.Host == "" {
+		return "", ErrUnsupportedURL
+	}
+	u.Scheme = "https"
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if port := u.Port(); port != "" && !(scheme == "http" && port == "80") && !(scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	u.Host = host
+	u.User = nil
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	if len(u.Path) > 1 {
+		u.Path = strings.TrimRight(u.Path, "/")
+		u.RawPath = ""
+	}
+	if u.Path == "/" {
+		u.Path = ""
+	}
+
+	u.RawQuery = cleanQuery(u.Query())
+
+	return u.String(), nil
+}
+
+// cleanQuery drops tracking parameters and encodes the rest in sorted order
+func cleanQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		if !isTrackingParam(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(url.QueryEscape(key))
+			b.WriteByte('=')
+			b.WriteString(url.QueryEscape(value))
+		}
+	}
+	return b.String()
+}
+
+func isTrackingParam(key string) bool {
+	key = strings.ToLower(key)
+	if trackingParams[key] {
+		return true
+	}
+	for _, prefix := range trackingPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -135,8 +135,15 @@ export function ArticleCard({ article }: ArticleCardProps) {
             </h2>
           </a>
 
-          {/* Description */}
-          {article.description && (
+          {/* Description, or the search snippet with matches highlighted */}
+          {article.headline ? (
+            <p
+              className="text-sm mb-3 line-clamp-2 [&_mark]:bg-transparent [&_mark]:font-semibold [&_mark]:text-[var(--color-accent-primary)]"
+              style={{ color: 'var(--color-text-secondary)' }}
+              // Escaped by the API apart from the <mark> tags
+              dangerouslySetInnerHTML={{ __html: article.headline }}
+            />
+          ) : article.description && (
             <p
               className="text-sm mb-3 line-clamp-2"
               style={{ color: 'var(--color-text-secondary)' }}
@@ -150,7 +157,24 @@ export function ArticleCard({ article }: ArticleCardProps) {
             className="flex items-center gap-3 text-xs mb-3"
             style={{ color: 'var(--color-text-muted)' }}
           >
-            <span className="font-medium">{article.source_name}</span>
+            <span className="flex items-center gap-1.5 font-medium">
+              {article.favicon_url && (
+                <img
+                  src={`${article.favicon_url}?w=32`}
+                  alt=""
+                  className="w-4 h-4 rounded-sm"
+                  loading="lazy"
+                />
+              )}
+              {article.source_name}
+            </span>
+            {article.also_covered_by && article.also_covered_by.length > 0 && (
+              <span
+                title={article.also_covered_by.map((c) => c.source_name).join(', ')}
+              >
+                +{article.also_covered_by.length} more {article.also_covered_by.length === 1 ? 'source' : 'sources'}
+              </span>
+            )}
             <span className="flex items-center gap-1">
               <Clock size={12} />
               {article.reading_time_minutes} min read
@@ -33,17 +33,17 @@ export function Feed() {
     error,
   } = useInfiniteQuery({
     queryKey: ['articles', selectedTags, effectiveSearch, sortBy],
-    queryFn: ({ pageParam = 1 }) =>
+    // Cursors keep pages stable while new articles arrive
+    queryFn: ({ pageParam }) =>
       getArticles({
         tags: selectedTags,
         search: effectiveSearch,
         sort_by: sortBy,
-        page: pageParam,
+        cursor: pageParam,
         page_size: 20,
       }),
-    getNextPageParam: (lastPage) =>
-      lastPage.has_more ? lastPage.page + 1 : undefined,
-    initialPageParam: 1,
+    getNextPageParam: (lastPage) => lastPage.next_cursor ?? undefined,
+    initialPageParam: undefined as string | undefined,
   });
 
   // Intersection observer for infinite scroll
@@ -77,6 +77,9 @@ export function Feed() {
     { value: 'newest', label: 'Newest' },
     { value: 'popular', label: 'Most Popular' },
     { value: 'trending', label: 'Trending' },
+    ...(effectiveSearch
+      ? [{ value: 'relevance' as SortOption, label: 'Relevance' }]
+      : []),
   ];
 
   return (
@@ -119,7 +122,7 @@ export function Feed() {
               style={{ borderRadius: 0 }}
             >
               <Filter size={16} />
-              {sortOptions.find((o) => o.value === sortBy)?.label}
+              {sortOptions.find((o) => o.value === sortBy)?.label ?? 'Newest'}
               <ChevronDown size={16} />
             </button>
 
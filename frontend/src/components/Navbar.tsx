@@ -2,10 +2,12 @@ import { useState, useEffect, useRef } from 'react';
 import { Link, useNavigate } from 'react-router-dom';
 import { Search, Moon, Sun, Menu, X, LogOut, User, Bookmark } from 'lucide-react';
 import { useAuthStore, useThemeStore, useFilterStore } from '../stores';
+import { SearchPanel } from './SearchPanel';
 
 export function Navbar() {
   const [isMenuOpen, setIsMenuOpen] = useState(false);
   const [isUserMenuOpen, setIsUserMenuOpen] = useState(false);
+  const [isSearchFocused, setIsSearchFocused] = useState(false);
   const searchRef = useRef<HTMLInputElement>(null);
   const userMenuRef = useRef<HTMLDivElement>(null);
   const navigate = useNavigate();
@@ -42,13 +44,18 @@ export function Navbar() {
     return () => document.removeEventListener('mousedown', handleClickOutside);
   }, []);
 
-  const handleSearch = (e: React.FormEvent) => {
-    e.preventDefault();
+  const showAllResults = () => {
     if (searchQuery.trim()) {
       navigate(`/?search=${encodeURIComponent(searchQuery.trim())}`);
+      searchRef.current?.blur();
     }
   };
 
+  const handleSearch = (e: React.FormEvent) => {
+    e.preventDefault();
+    showAllResults();
+  };
+
   return (
     <nav className="navbar-blur sticky top-0 z-50 border-b" style={{
       backgroundColor: 'rgba(var(--color-bg-secondary-rgb, 255, 255, 255), 0.8)',
@@ -77,6 +84,8 @@ export function Navbar() {
               placeholder="Search articles..."
               value={searchQuery}
               onChange={(e) => setSearchQuery(e.target.value)}
+              onFocus={() => setIsSearchFocused(true)}
+              onBlur={() => setIsSearchFocused(false)}
               className="input pl-10 pr-16"
               style={{
                 borderRadius: 0,
@@ -92,6 +101,7 @@ export function Navbar() {
             >
               <span className="text-xs">Cmd</span>K
             </kbd>
+            {isSearchFocused && <SearchPanel query={searchQuery} onSeeAll={showAllResults} />}
           </div>
         </form>
 
@@ -0,0 +1,200 @@
+import { useEffect, useState } from 'react';
+import { useQuery, keepPreviousData } from '@tanstack/react-query';
+import { ArrowRight, X } from 'lucide-react';
+import { search } from '../lib/api';
+import type { FacetCount } from '../types';
+
+interface SearchPanelProps {
+  query: string;
+  onSeeAll: () => void;
+}
+
+interface Narrowing {
+  tags: string[];
+  source?: string;
+  author?: string;
+  date?: FacetCount;
+}
+
+// Live results for the Cmd+K search box, with facets to narrow them down
+export function SearchPanel({ query, onSeeAll }: SearchPanelProps) {
+  const [debouncedQuery, setDebouncedQuery] = useState(query);
+  const [narrowing, setNarrowing] = useState<Narrowing>({ tags: [] });
+
+  useEffect(() => {
+    const timeout = setTimeout(() => setDebouncedQuery(query.trim()), 250);
+    return () => clearTimeout(timeout);
+  }, [query]);
+
+  const { data, isFetching } = useQuery({
+    queryKey: ['search', debouncedQuery, narrowing],
+    queryFn: () =>
+      search({
+        q: debouncedQuery,
+        tags: narrowing.tags,
+        source: narrowing.source,
+        author: narrowing.author,
+        from: narrowing.date?.value,
+        page_size: 8,
+      }),
+    enabled: debouncedQuery.length > 0,
+    placeholderData: keepPreviousData,
+  });
+
+  const toggleTag = (slug: string) =>
+    setNarrowing((n) => ({
+      ...n,
+      tags: n.tags.includes(slug) ? n.tags.filter((t) => t !== slug) : [...n.tags, slug],
+    }));
+
+  const hasNarrowing =
+    narrowing.tags.length > 0 || narrowing.source || narrowing.author || narrowing.date;
+
+  if (!debouncedQuery) return null;
+
+  return (
+    <div
+      className="absolute left-0 right-0 top-full mt-2 shadow-lg border z-50 fade-in max-h-[70vh] overflow-y-auto"
+      style={{
+        backgroundColor: 'var(--color-bg-secondary)',
+        borderColor: 'var(--color-border)',
+      }}
+    >
+      {data && (
+        <div className="p-3 border-b space-y-2" style={{ borderColor: 'var(--color-border)' }}>
+          <FacetRow
+            label="Tags"
+            facets={data.facets.tags}
+            isSelected={(f) => narrowing.tags.includes(f.value)}
+            onToggle={(f) => toggleTag(f.value)}
+          />
+          <FacetRow
+            label="Sources"
+            facets={data.facets.sources}
+            isSelected={(f) => narrowing.source === f.value}
+            onToggle={(f) =>
+              setNarrowing((n) => ({ ...n, source: n.source === f.value ? undefined : f.value }))
+            }
+          />
+          <FacetRow
+            label="Authors"
+            facets={data.facets.authors}
+            isSelected={(f) => narrowing.author === f.value}
+            onToggle={(f) =>
+              setNarrowing((n) => ({ ...n, author: n.author === f.value ? undefined : f.value }))
+            }
+          />
+          <FacetRow
+            label="Published"
+            facets={data.facets.dates}
+            isSelected={(f) => narrowing.date?.label === f.label}
+            onToggle={(f) =>
+              setNarrowing((n) => ({ ...n, date: n.date?.label === f.label ? undefined : f }))
+            }
+          />
+          {hasNarrowing && (
+            <button
+              type="button"
+              onMouseDown={(e) => e.preventDefault()}
+              onClick={() => setNarrowing({ tags: [] })}
+              className="flex items-center gap-1 text-xs"
+              style={{ color: 'var(--color-text-muted)' }}
+            >
+              <X size={12} />
+              Clear filters
+            </button>
+          )}
+        </div>
+      )}
+
+      <div className={isFetching ? 'opacity-60' : undefined}>
+        {data?.articles.map((article) => (
+          <a
+            key={article.id}
+            href={article.url}
+            target="_blank"
+            rel="noopener noreferrer"
+            onMouseDown={(e) => e.preventDefault()}
+            className="block px-3 py-2 hover:bg-[var(--color-bg-tertiary)]"
+          >
+            <div
+              className="text-sm font-medium line-clamp-1"
+              style={{ color: 'var(--color-text-primary)' }}
+            >
+              {article.title}
+            </div>
+            {article.headline && (
+              <div
+                className="text-xs line-clamp-1 [&_mark]:bg-transparent [&_mark]:font-semibold [&_mark]:text-[var(--color-accent-primary)]"
+                style={{ color: 'var(--color-text-secondary)' }}
+                // Escaped by the API apart from the <mark> tags
+                dangerouslySetInnerHTML={{ __html: article.headline }}
+              />
+            )}
+            <div className="text-xs mt-0.5" style={{ color: 'var(--color-text-muted)' }}>
+              {article.source_name}
+            </div>
+          </a>
+        ))}
+
+        {data && data.articles.length === 0 && (
+          <p className="px-3 py-4 text-sm" style={{ color: 'var(--color-text-muted)' }}>
+            No articles found
+          </p>
+        )}
+      </div>
+
+      {data && data.total_count > 0 && (
+        <button
+          type="button"
+          onMouseDown={(e) => e.preventDefault()}
+          onClick={onSeeAll}
+          className="w-full flex items-center justify-between px-3 py-2 text-sm border-t hover:bg-[var(--color-bg-tertiary)]"
+          style={{ borderColor: 'var(--color-border)', color: 'var(--color-accent-primary)' }}
+        >
+          See all {data.total_count} results
+          <ArrowRight size={14} />
+        </button>
+      )}
+    </div>
+  );
+}
+
+interface FacetRowProps {
+  label: string;
+  facets: FacetCount[];
+  isSelected: (facet: FacetCount) => boolean;
+  onToggle: (facet: FacetCount) => void;
+}
+
+function FacetRow({ label, facets, isSelected, onToggle }: FacetRowProps) {
+  if (facets.length === 0) return null;
+
+  return (
+    <div className="flex flex-wrap items-center gap-1.5">
+      <span className="text-xs w-16 flex-shrink-0" style={{ color: 'var(--color-text-muted)' }}>
+        {label}
+      </span>
+      {facets.map((facet) => {
+        const selected = isSelected(facet);
+        return (
+          <button
+            type="button"
+            key={facet.value}
+            // Keep focus in the search box so the panel stays open
+            onMouseDown={(e) => e.preventDefault()}
+            onClick={() => onToggle(facet)}
+            className="tag text-xs"
+            style={{
+              borderRadius: 0,
+              backgroundColor: selected ? 'var(--color-accent-primary)' : 'var(--color-bg-tertiary)',
+              color: selected ? 'white' : 'var(--color-text-secondary)',
+            }}
+          >
+            {facet.label} <span className="opacity-70">{facet.count}</span>
+          </button>
+        );
+      })}
+    </div>
+  );
+}
@@ -3,3 +3,4 @@ export { Sidebar } from './Sidebar';
 export { ArticleCard, ArticleCardSkeleton } from './ArticleCard';
 export { Feed } from './Feed';
 export { TrendingSection } from './TrendingSection';
+export { SearchPanel } from './SearchPanel';
@@ -1,4 +1,4 @@
-import type { Article, ArticlesResponse, Tag, ArticleFilters, SuccessResponse, VoteResponse, UserProfile, ReadingStats } from '../types';
+import type { Article, ArticlesResponse, Tag, ArticleFilters, SearchFilters, SearchResponse, SuccessResponse, VoteResponse, UserProfile, ReadingStats } from '../types';
 
 const API_BASE = '/api';
 
@@ -31,6 +31,7 @@ export async function getArticles(filters: ArticleFilters = {}): Promise<Article
     if (filters.tags?.length) params.set('tags', filters.tags.join(','));
     if (filters.search) params.set('search', filters.search);
     if (filters.sort_by) params.set('sort_by', filters.sort_by);
+    if (filters.cursor) params.set('cursor', filters.cursor);
     if (filters.page) params.set('page', String(filters.page));
     if (filters.page_size) params.set('page_size', String(filters.page_size));
 
@@ -46,6 +47,23 @@ export async function getTrendingArticles(limit = 10): Promise<Article[]> {
     return fetchAPI<Article[]>(`/articles/trending?limit=${limit}`);
 }
 
+// Search
+export async function search(filters: SearchFilters): Promise<SearchResponse> {
+    const params = new URLSearchParams();
+
+    if (filters.q) params.set('q', filters.q);
+    if (filters.tags?.length) params.set('tags', filters.tags.join(','));
+    if (filters.source) params.set('source', filters.source);
+    if (filters.author) params.set('author', filters.author);
+    if (filters.from) params.set('from', filters.from);
+    if (filters.to) params.set('to', filters.to);
+    if (filters.sort_by) params.set('sort_by', filters.sort_by);
+    if (filters.page) params.set('page', String(filters.page));
+    if (filters.page_size) params.set('page_size', String(filters.page_size));
+
+    return fetchAPI<SearchResponse>(`/search?${params.toString()}`);
+}
+
 // Tags
 export async function getTags(): Promise<Tag[]> {
     return fetchAPI<Tag[]>('/tags');
@@ -141,10 +141,10 @@ export const useThemeStore = create<ThemeState>()(
 interface FilterState {
     selectedTags: string[];
     searchQuery: string;
-    sortBy: 'newest' | 'popular' | 'trending';
+    sortBy: 'newest' | 'popular' | 'trending' | 'relevance';
     toggleTag: (slug: string) => void;
     setSearchQuery: (query: string) => void;
-    setSortBy: (sort: 'newest' | 'popular' | 'trending') => void;
+    setSortBy: (sort: 'newest' | 'popular' | 'trending' | 'relevance') => void;
     clearFilters: () => void;
 }
 
@@ -19,6 +19,7 @@ export interface Article {
   published_at: string | null;
   source_id: string | null;
   source_name: string;
+  favicon_url: string | null;
   image_url: string | null;
   reading_time_minutes: number;
   upvotes: number;
@@ -28,6 +29,14 @@ export interface Article {
   tags: Tag[];
   is_bookmarked?: boolean;
   user_vote?: 'up' | 'down' | null;
+  also_covered_by?: ArticleCoverage[];
+  headline?: string;
+}
+
+export interface ArticleCoverage {
+  article_id: string;
+  source_name: string;
+  url: string;
 }
 
 export interface ArticlesResponse {
@@ -36,6 +45,7 @@ export interface ArticlesResponse {
   page: number;
   page_size: number;
   has_more: boolean;
+  next_cursor: string | null;
 }
 
 export interface UserProfile {
@@ -64,7 +74,26 @@ export interface RSSSource {
   favicon_url: string | null;
   active: boolean;
   last_fetched_at: string | null;
+  poll_interval_minutes: number;
+  next_fetch_at: string | null;
+  consecutive_failures: number;
+  last_error: string | null;
+  last_status_code: number | null;
+  last_success_at: string | null;
   created_at: string;
+  tags?: Tag[];
+  filters: SourceFilters;
+  fetch_full_content: boolean;
+}
+
+export interface SourceFilters {
+  include: FilterRule[] | null;
+  exclude: FilterRule[] | null;
+}
+
+export interface FilterRule {
+  field: 'keyword' | 'regex' | 'author' | 'category';
+  value: string;
 }
 
 export interface ErrorResponse {
@@ -85,12 +114,43 @@ export interface VoteResponse {
 }
 
 // Filter types
-export type SortOption = 'newest' | 'popular' | 'trending';
+export type SortOption = 'newest' | 'popular' | 'trending' | 'relevance';
 
 export interface ArticleFilters {
   tags?: string[];
   search?: string;
   sort_by?: SortOption;
+  cursor?: string;
+  page?: number;
+  page_size?: number;
+}
+
+// Search types
+export interface SearchFilters {
+  q: string;
+  tags?: string[];
+  source?: string;
+  author?: string;
+  from?: string;
+  to?: string;
+  sort_by?: 'relevance' | 'newest';
   page?: number;
   page_size?: number;
 }
+
+export interface FacetCount {
+  value: string;
+  label: string;
+  count: number;
+}
+
+export interface SearchFacets {
+  tags: FacetCount[];
+  sources: FacetCount[];
+  authors: FacetCount[];
+  dates: FacetCount[];
+}
+
+export interface SearchResponse extends ArticlesResponse {
+  facets: SearchFacets;
+}
@@ -30,8 +30,25 @@ CREATE TABLE rss_sources (
     name TEXT NOT NULL,
     url TEXT UNIQUE NOT NULL,
     favicon_url TEXT,
+    favicon_checked_at TIMESTAMP WITH TIME ZONE,
     active BOOLEAN DEFAULT TRUE,
     last_fetched_at TIMESTAMP WITH TIME ZONE,
+    -- Adaptive polling schedule
+    poll_interval_minutes INTEGER NOT NULL DEFAULT 30,
+    next_fetch_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    -- Fetch health
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    last_status_code INTEGER,
+    last_success_at TIMESTAMP WITH TIME ZONE,
+    -- Conditional GET validators from the last successful fetch
+    etag TEXT,
+    last_modified TEXT,
+    content_hash TEXT,
+    -- Include/exclude rules applied to feed items before they are stored
+    filters JSONB NOT NULL DEFAULT '{"include": [], "exclude": []}',
+    -- Replace summary-only feed content with the linked page's article text
+    fetch_full_content BOOLEAN NOT NULL DEFAULT FALSE,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
@@ -40,6 +57,7 @@ CREATE TABLE articles (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     title TEXT NOT NULL,
     url TEXT UNIQUE NOT NULL,
+    canonical_url TEXT UNIQUE,
     description TEXT,
     content TEXT,
     author TEXT,
@@ -50,10 +68,91 @@ CREATE TABLE articles (
     reading_time_minutes INTEGER DEFAULT 5,
     upvotes INTEGER DEFAULT 0,
     downvotes INTEGER DEFAULT 0,
+    -- Feed item tracking for picking up edits
+    guid TEXT,
+    content_hash TEXT,
+    feed_updated_at TIMESTAMP WITH TIME ZONE,
+    -- Near-duplicate detection: title/description simhash and the first
+    -- article of the story cluster this one belongs to
+    simhash BIGINT,
+    cluster_id UUID REFERENCES articles(id) ON DELETE SET NULL,
+    -- Full-text search document, weighting title over description over content
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(description, '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(content, '')), 'C')
+    ) STORED,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
+-- Article Revisions (previous titles and descriptions of edited feed items)
+CREATE TABLE article_revisions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    article_id UUID REFERENCES articles(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- RSS Source Tags (junction table)
+CREATE TABLE rss_source_tags (
+    source_id UUID REFERENCES rss_sources(id) ON DELETE CASCADE,
+    tag_id UUID REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (source_id, tag_id)
+);
+
+-- RSS Fetch Runs (ingestion report per cron or manual run)
+CREATE TABLE fetch_runs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    trigger TEXT NOT NULL CHECK (trigger IN ('cron', 'manual')),
+    status TEXT NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'completed', 'failed')),
+    started_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    finished_at TIMESTAMP WITH TIME ZONE,
+    sources_attempted INTEGER NOT NULL DEFAULT 0,
+    items_seen INTEGER NOT NULL DEFAULT 0,
+    items_filtered INTEGER NOT NULL DEFAULT 0,
+    articles_inserted INTEGER NOT NULL DEFAULT 0,
+    articles_updated INTEGER NOT NULL DEFAULT 0,
+    duplicates_skipped INTEGER NOT NULL DEFAULT 0,
+    errors JSONB NOT NULL DEFAULT '[]',
+    error TEXT
+);
+
+-- Tag rule runs (re-application of the tag rules to stored articles)
+CREATE TABLE tag_rule_runs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    status TEXT NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'completed', 'failed')),
+    started_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    finished_at TIMESTAMP WITH TIME ZONE,
+    articles_scanned INTEGER NOT NULL DEFAULT 0,
+    tags_added INTEGER NOT NULL DEFAULT 0,
+    error TEXT
+);
+
+-- Page metadata cache: Open Graph / Twitter card fields read from article
+-- pages, one row per URL including pages that could not be fetched, which
+-- are retried after a few hours
+CREATE TABLE page_metadata (
+    url TEXT PRIMARY KEY,
+    image_url TEXT,
+    description TEXT,
+    author TEXT,
+    error TEXT,
+    fetched_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Proxied images: original URL per hash served by /api/images/:hash
+CREATE TABLE images (
+    hash TEXT PRIMARY KEY,
+    url TEXT NOT NULL,
+    content_type TEXT,
+    size_bytes INTEGER,
+    error TEXT,
+    fetched_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
 -- Article Tags (junction table)
 CREATE TABLE article_tags (
     article_id UUID REFERENCES articles(id) ON DELETE CASCADE,
@@ -61,6 +160,16 @@ CREATE TABLE article_tags (
     PRIMARY KEY (article_id, tag_id)
 );
 
+-- Tag rules: auto-tag ingested articles whose title or body matches
+CREATE TABLE tag_rules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    match_type TEXT NOT NULL CHECK (match_type IN ('keyword', 'regex')),
+    pattern TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE (tag_id, match_type, pattern)
+);
+
 -- Bookmarks
 CREATE TABLE bookmarks (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -93,12 +202,25 @@ CREATE TABLE reading_history (
 CREATE INDEX idx_articles_published_at ON articles(published_at DESC);
 CREATE INDEX idx_articles_upvotes ON articles(upvotes DESC);
 CREATE INDEX idx_articles_source_id ON articles(source_id);
+CREATE UNIQUE INDEX idx_articles_source_guid ON articles(source_id, guid) WHERE guid IS NOT NULL;
+CREATE INDEX idx_articles_cluster_id ON articles(cluster_id);
+CREATE INDEX idx_articles_created_at_simhash ON articles(created_at) WHERE simhash IS NOT NULL;
+CREATE INDEX idx_articles_search_vector ON articles USING GIN(search_vector);
+CREATE INDEX idx_articles_newest ON articles(published_at DESC NULLS LAST, created_at DESC NULLS LAST, id DESC);
+CREATE INDEX idx_article_revisions_article_id ON article_revisions(article_id);
+CREATE INDEX idx_rss_sources_next_fetch_at ON rss_sources(next_fetch_at) WHERE active = TRUE;
 CREATE INDEX idx_article_tags_article_id ON article_tags(article_id);
 CREATE INDEX idx_article_tags_tag_id ON article_tags(tag_id);
+CREATE INDEX idx_tag_rules_tag_id ON tag_rules(tag_id);
 CREATE INDEX idx_bookmarks_user_id ON bookmarks(user_id);
+CREATE INDEX idx_bookmarks_user_id_created_at ON bookmarks(user_id, created_at DESC NULLS LAST, id DESC);
 CREATE INDEX idx_votes_user_id ON votes(user_id);
 CREATE INDEX idx_votes_article_id ON votes(article_id);
 CREATE INDEX idx_reading_history_user_id ON reading_history(user_id);
+CREATE INDEX idx_fetch_runs_started_at ON fetch_runs(started_at DESC);
+CREATE INDEX idx_tag_rule_runs_started_at ON tag_rule_runs(started_at DESC);
+-- At most one tag rule run at a time, across all API instances
+CREATE UNIQUE INDEX idx_tag_rule_runs_running ON tag_rule_runs((TRUE)) WHERE status = 'running';
 
 -- Enable Row Level Security
 ALTER TABLE user_profiles ENABLE ROW LEVEL SECURITY;
@@ -106,9 +228,16 @@ ALTER TABLE articles ENABLE ROW LEVEL SECURITY;
 ALTER TABLE tags ENABLE ROW LEVEL SECURITY;
 ALTER TABLE rss_sources ENABLE ROW LEVEL SECURITY;
 ALTER TABLE article_tags ENABLE ROW LEVEL SECURITY;
+ALTER TABLE tag_rules ENABLE ROW LEVEL SECURITY;
+ALTER TABLE page_metadata ENABLE ROW LEVEL SECURITY;
+ALTER TABLE images ENABLE ROW LEVEL SECURITY;
 ALTER TABLE bookmarks ENABLE ROW LEVEL SECURITY;
 ALTER TABLE votes ENABLE ROW LEVEL SECURITY;
 ALTER TABLE reading_history ENABLE ROW LEVEL SECURITY;
+ALTER TABLE rss_source_tags ENABLE ROW LEVEL SECURITY;
+ALTER TABLE fetch_runs ENABLE ROW LEVEL SECURITY;
+ALTER TABLE tag_rule_runs ENABLE ROW LEVEL SECURITY;
+ALTER TABLE article_revisions ENABLE ROW LEVEL SECURITY;
 
 -- Policies
 
@@ -275,3 +404,15 @@ INSERT INTO tags (name, slug, color) VALUES
     ('Career', 'career', '#f59e0b'),
     ('Open Source', 'open-source', '#22c55e'),
     ('System Design', 'system-design', '#ec4899');
+
+-- Register images stored before the image proxy existed, so that their
+-- proxied URLs can be served. Hashes match imageproxy.Hash.
+INSERT INTO images (hash, url)
+SELECT encode(sha256(convert_to(url, 'UTF8')), 'hex'), url
+FROM (
+    SELECT image_url AS url FROM articles
+    UNION
+    SELECT favicon_url FROM rss_sources
+) stored
+WHERE url IS NOT NULL AND url <> ''
+ON CONFLICT (hash) DO NOTHING;